@@ -0,0 +1,354 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Archives that --into-archives can list, by suffix. The rest of archiveTypes need decompressors outside the standard library
+var listableArchiveTypes = map[string]string{
+	".zip":     "zip",
+	".jar":     "zip",
+	".kra":     "zip",
+	".tar":     "tar",
+	".tar.gz":  "tar.gz",
+	".tgz":     "tar.gz",
+	".tar.bz2": "tar.bz2",
+	".tb2":     "tar.bz2",
+	".tbz":     "tar.bz2",
+	".tbz2":    "tar.bz2",
+	".tz2":     "tar.bz2",
+}
+
+// A file or directory inside an archive, for --into-archives.
+// It is both the fs.DirEntry and the fs.FileInfo of an Entry
+type archiveFile struct {
+	name       string
+	size       int64
+	mode       fs.FileMode
+	modTime    time.Time
+	owner      string                  // Empty if the archive doesn't store it, like zip
+	group      string                  // Empty if the archive doesn't store it, like zip
+	linkTarget string                  // Target of a symlink
+	target     *archiveFile            // What linkTarget points to in the archive, nil if it is outside of it or missing
+	children   map[string]*archiveFile // Keyed by name, nil unless it is a directory
+}
+
+func (f *archiveFile) Name() string               { return f.name }
+func (f *archiveFile) Size() int64                { return f.size }
+func (f *archiveFile) Mode() fs.FileMode          { return f.mode }
+func (f *archiveFile) Type() fs.FileMode          { return f.mode.Type() }
+func (f *archiveFile) ModTime() time.Time         { return f.modTime }
+func (f *archiveFile) IsDir() bool                { return f.mode.IsDir() }
+func (f *archiveFile) Sys() any                   { return nil }
+func (f *archiveFile) Info() (fs.FileInfo, error) { return f, nil }
+
+// Returns the kind of archive ("zip", "tar", "tar.gz" or "tar.bz2") from the suffix of name, or "" if it can't be listed
+func listableArchiveType(name string) string {
+	lower := strings.ToLower(name)
+	longest := -1
+	ret := ""
+	for suffix, kind := range listableArchiveTypes {
+		if len(suffix) > longest && strings.HasSuffix(lower, suffix) {
+			ret = kind
+			longest = len(suffix)
+		}
+	}
+	return ret
+}
+
+// Splits a path like "archive.tar.gz/sub/dir" into the archive file and the slash-separated path inside it ("" for the archive itself).
+// ok is false if no part of the path is an archive that can be listed
+func SplitArchivePath(p string) (archivePath string, inner string, ok bool) {
+	parts := strings.Split(filepath.Clean(p), string(os.PathSeparator))
+	for i := range parts {
+		if listableArchiveType(parts[i]) == "" {
+			continue
+		}
+
+		prefix := strings.Join(parts[:i+1], string(os.PathSeparator))
+		stat, err := os.Stat(prefix)
+		if err != nil || !stat.Mode().IsRegular() {
+			continue
+		}
+
+		return prefix, strings.Join(parts[i+1:], "/"), true
+	}
+
+	return "", "", false
+}
+
+// Adds a file at the slash-separated path p, directories leading up to it that the archive doesn't list are made up
+func (f *archiveFile) add(p string, file *archiveFile) {
+	// Leading slashes and ".." would escape the archive
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return
+	}
+
+	dir := f
+	names := strings.Split(p, "/")
+	for _, name := range names[:len(names)-1] {
+		child, ok := dir.children[name]
+		if !ok || child.children == nil {
+			child = &archiveFile{name: name, mode: fs.ModeDir | 0o755, modTime: f.modTime, children: make(map[string]*archiveFile)}
+			dir.children[name] = child
+		}
+		dir = child
+	}
+
+	file.name = names[len(names)-1]
+	if file.IsDir() {
+		file.children = make(map[string]*archiveFile)
+		if existing, ok := dir.children[file.name]; ok && existing.children != nil {
+			file.children = existing.children
+		}
+	}
+	dir.children[file.name] = file
+}
+
+func readZip(root *archiveFile, archivePath string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, f := range reader.File {
+		file := &archiveFile{
+			size:    int64(f.UncompressedSize64),
+			mode:    f.Mode(),
+			modTime: f.Modified,
+		}
+
+		// Zip stores the target of a symlink as its contents
+		if f.Mode()&fs.ModeSymlink != 0 {
+			if contents, err := f.Open(); err == nil {
+				target, _ := io.ReadAll(io.LimitReader(contents, 4096))
+				file.linkTarget = string(target)
+				contents.Close()
+			}
+		}
+
+		root.add(f.Name, file)
+	}
+
+	return nil
+}
+
+func readTar(root *archiveFile, archivePath string, kind string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	switch kind {
+	case "tar.gz":
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "tar.bz2":
+		reader = bzip2.NewReader(file)
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Extended headers are applied to the next entry by archive/tar, the rest aren't files
+		switch header.Typeflag {
+		case tar.TypeXHeader, tar.TypeXGlobalHeader, tar.TypeGNULongName, tar.TypeGNULongLink:
+			continue
+		}
+
+		owner := header.Uname
+		if owner == "" {
+			owner = strconv.Itoa(header.Uid)
+		}
+		group := header.Gname
+		if group == "" {
+			group = strconv.Itoa(header.Gid)
+		}
+
+		root.add(header.Name, &archiveFile{
+			size:       header.Size,
+			mode:       header.FileInfo().Mode(),
+			modTime:    header.ModTime,
+			owner:      owner,
+			group:      group,
+			linkTarget: header.Linkname,
+		})
+	}
+}
+
+// Returns the top level of the archive, which is read once.
+// Directories are made up for the files in them if the archive doesn't list them
+func ReadArchive(archivePath string) (*archiveFile, error) {
+	if root, ok := caches.archives[archivePath]; ok {
+		return root, nil
+	}
+
+	kind := listableArchiveType(archivePath)
+	if kind == "" {
+		return nil, errors.New("not a zip or tar archive")
+	}
+
+	stat, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &archiveFile{name: filepath.Base(archivePath), mode: fs.ModeDir | 0o755, modTime: stat.ModTime(), children: make(map[string]*archiveFile)}
+	if kind == "zip" {
+		err = readZip(root, archivePath)
+	} else {
+		err = readTar(root, archivePath, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Symlinks are resolved once everything has been read, since they can point to files later in the archive
+	var resolveSymlinks func(dirPath string, dir *archiveFile)
+	resolveSymlinks = func(dirPath string, dir *archiveFile) {
+		for name, child := range dir.children {
+			if child.IsDir() {
+				resolveSymlinks(path.Join(dirPath, name), child)
+			} else if child.mode&fs.ModeSymlink != 0 && !path.IsAbs(child.linkTarget) {
+				child.target, _ = root.Lookup(path.Join(dirPath, child.linkTarget))
+			}
+		}
+	}
+	resolveSymlinks("", root)
+
+	caches.archives[archivePath] = root
+	return root, nil
+}
+
+// Like os.Lstat() for a path inside an archive, like "archive.zip/sub/file"
+func LstatInArchive(p string) (fs.FileInfo, error) {
+	archivePath, inner, ok := SplitArchivePath(p)
+	if !ok || inner == "" {
+		return nil, &fs.PathError{Op: "lstat", Path: p, Err: syscall.ENOENT}
+	}
+
+	root, err := ReadArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok := root.Lookup(inner)
+	if !ok {
+		return nil, &fs.PathError{Op: "lstat", Path: p, Err: syscall.ENOENT}
+	}
+	return file, nil
+}
+
+// Returns the file at the slash-separated path inside the archive, "" is the archive itself
+func (f *archiveFile) Lookup(p string) (*archiveFile, bool) {
+	file := f
+	for _, name := range strings.Split(p, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+
+		child, ok := file.children[name]
+		if !ok {
+			return nil, false
+		}
+		file = child
+	}
+
+	return file, true
+}
+
+// Returns the entries of a directory in an archive sorted by name like os.ReadDir(), dirPath is the path it was listed as
+func (f *archiveFile) Entries(dirPath string) []Entry {
+	var names []string
+	for name := range f.children {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	ret := make([]Entry, len(names))
+	for i, name := range names {
+		ret[i] = Entry{f.children[name], filepath.Join(dirPath, name)}
+	}
+	return ret
+}
+
+// Like WalkDirectoryTree() for a directory inside an archive
+func ArchiveDirectoryTree(root string, dir *archiveFile, options Options) DirectoryTree {
+	tree := DirectoryTree{
+		root:     filepath.Clean(root),
+		children: make(map[string][]Entry),
+		errors:   make(map[string]error),
+	}
+
+	var walk func(dirPath string, dir *archiveFile)
+	walk = func(dirPath string, dir *archiveFile) {
+		for _, entry := range dir.Entries(dirPath) {
+			if IsFiltered(entry, options) {
+				continue
+			}
+
+			tree.children[dirPath] = append(tree.children[dirPath], entry)
+
+			if entry.IsDir() && (options.all || !strings.HasPrefix(entry.Name(), ".")) {
+				walk(entry.path, entry.DirEntry.(*archiveFile))
+			}
+		}
+	}
+	walk(tree.root, dir)
+
+	return tree
+}
+
+// Collects the entries of a path inside an archive for CollectEntries(), like "archive.zip" or "archive.tar.gz/sub/dir"
+func CollectArchiveEntries(p string, archivePath string, inner string, directory bool, recursive bool, options Options) ([]Entry, []DirectoryTree) {
+	root, err := ReadArchive(archivePath)
+	if err != nil {
+		reportError("Failed to read archive", archivePath, err, exitSeriousProblem, options)
+		return nil, nil
+	}
+
+	file, ok := root.Lookup(inner)
+	if !ok {
+		reportError("Failed to stat", p, &fs.PathError{Op: "stat", Path: p, Err: syscall.ENOENT}, exitSeriousProblem, options)
+		return nil, nil
+	}
+
+	if !file.IsDir() || directory {
+		return []Entry{{file, p}}, nil
+	}
+
+	if recursive {
+		return nil, []DirectoryTree{ArchiveDirectoryTree(p, file, options)}
+	}
+
+	return file.Entries(p), nil
+}
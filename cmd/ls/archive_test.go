@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestListableArchiveType(t *testing.T) {
+	type TestCase struct {
+		name     string
+		expected string
+	}
+
+	tests := []TestCase{
+		{"a.zip", "zip"},
+		{"A.JAR", "zip"},
+		{"a.tar", "tar"},
+		{"a.tar.gz", "tar.gz"},
+		{"a.tgz", "tar.gz"},
+		{"a.tar.bz2", "tar.bz2"},
+		{"a.tar.xz", ""},
+		{"a.gz", ""},
+		{"zip", ""},
+	}
+	for _, test := range tests {
+		result := listableArchiveType(test.name)
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.name, "but got", result)
+		}
+	}
+}
+
+func writeTestZip(t *testing.T, archivePath string) {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	for _, name := range []string{"top.txt", "sub/", "sub/inner.go", "implicit/deep/file.png"} {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+	writer.Close()
+}
+
+func writeTestTarGz(t *testing.T, archivePath string) {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	defer gzipWriter.Close()
+	writer := tar.NewWriter(gzipWriter)
+	defer writer.Close()
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writer.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "./dir/", Mode: 0o755, ModTime: modTime, Uname: "alice", Gname: "staff"})
+	writer.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "./dir/script.sh", Mode: 0o755, Size: 5, ModTime: modTime, Uname: "alice", Gname: "staff"})
+	writer.Write([]byte("hello"))
+	writer.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "./link", Linkname: "dir/script.sh", ModTime: modTime})
+	writer.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "./broken", Linkname: "missing", ModTime: modTime})
+}
+
+func entryNames(entries []Entry) []string {
+	var ret []string
+	for _, e := range entries {
+		ret = append(ret, e.Name())
+	}
+	return ret
+}
+
+func TestReadZipArchive(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "test.zip")
+	writeTestZip(t, archivePath)
+	defer clearCaches()
+
+	root, err := ReadArchive(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := entryNames(root.Entries(archivePath))
+	if !slices.Equal(names, []string{"implicit", "sub", "top.txt"}) {
+		t.Fatal("Expected the top level of the archive, but got", names)
+	}
+
+	file, ok := root.Lookup("implicit/deep/file.png")
+	if !ok || file.Size() != int64(len("implicit/deep/file.png")) || file.IsDir() {
+		t.Fatal("Expected a file made up of its path, but got", file, ok)
+	}
+
+	dir, ok := root.Lookup("implicit/deep")
+	if !ok || !dir.IsDir() {
+		t.Fatal("Expected a directory to be made up for the file in it, but got", dir, ok)
+	}
+
+	if _, ok := root.Lookup("sub/missing"); ok {
+		t.Fatal("Expected sub/missing to not exist")
+	}
+}
+
+func TestReadTarArchive(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "test.tar.gz")
+	writeTestTarGz(t, archivePath)
+	defer clearCaches()
+
+	root, err := ReadArchive(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script, ok := root.Lookup("dir/script.sh")
+	if !ok || script.Size() != 5 || script.Mode().Perm() != 0o755 || script.owner != "alice" || script.group != "staff" {
+		t.Fatal("Expected the size, mode and owner from the tar header, but got", script, ok)
+	}
+	if FileCategory(script, archivePath+"/dir/script.sh") != "executable" {
+		t.Fatal("Expected the script to be executable")
+	}
+
+	link, _ := root.Lookup("link")
+	if link.target != script || FileCategory(link, archivePath+"/link") != "symlink" {
+		t.Fatal("Expected the symlink to point to the script, but got", link.target)
+	}
+
+	broken, _ := root.Lookup("broken")
+	if FileCategory(broken, archivePath+"/broken") != "symlink_broken" {
+		t.Fatal("Expected a broken symlink")
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "test.zip")
+	writeTestZip(t, archivePath)
+	os.Mkdir(filepath.Join(dir, "fake.zip"), 0o755)
+
+	type TestCase struct {
+		path            string
+		expectedArchive string
+		expectedInner   string
+		expectedOk      bool
+	}
+
+	tests := []TestCase{
+		{archivePath, archivePath, "", true},
+		{filepath.Join(archivePath, "sub", "inner.go"), archivePath, "sub/inner.go", true},
+		{filepath.Join(dir, "fake.zip", "x"), "", "", false},
+		{filepath.Join(dir, "missing.zip"), "", "", false},
+		{dir, "", "", false},
+	}
+	for _, test := range tests {
+		archive, inner, ok := SplitArchivePath(test.path)
+		if archive != test.expectedArchive || inner != test.expectedInner || ok != test.expectedOk {
+			t.Fatal("Expected", test.expectedArchive, test.expectedInner, test.expectedOk, "for", test.path, "but got", archive, inner, ok)
+		}
+	}
+}
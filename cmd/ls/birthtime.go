@@ -0,0 +1,13 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// Returns when the file was created, ok is false if it is unavailable
+func BirthTime(stat os.FileInfo, path string) (t time.Time, ok bool) {
+	return t, false
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Returns when the file was created, ok is false if the kernel or filesystem doesn't record it
+func BirthTime(stat os.FileInfo, path string) (t time.Time, ok bool) {
+	var statx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &statx)
+	if err != nil || statx.Mask&unix.STATX_BTIME == 0 {
+		return t, false
+	}
+
+	return time.Unix(statx.Btime.Sec, int64(statx.Btime.Nsec)), true
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Returns when the file was created, ok is false if it is unavailable
+func BirthTime(stat os.FileInfo, path string) (t time.Time, ok bool) {
+	attributes, ok := stat.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return t, false
+	}
+
+	return time.Unix(0, attributes.CreationTime.Nanoseconds()), true
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/kivattt/gogitstatus"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// Everything cached about the files while listing them, --watch replaces all of it before each redraw
+type fileCaches struct {
+	rootOfDirectory      map[string]string // "" if not in a repository
+	statusOfRoot         map[string]map[string]gogitstatus.ChangedFile
+	detailedStatusOfRoot map[string]map[string]GitDetailedStatus
+	gitIgnore            map[string]*ignore.GitIgnore // Keyed by the directory containing the .gitignore, nil if there is none
+	gitExclude           map[string]*ignore.GitIgnore // .git/info/exclude, keyed by repository root
+	gitIndex             map[string]map[string]gogitstatus.GitIndexEntry
+	packIndexes          map[string][]*packIndex // Keyed by the objects directory
+	archives             map[string]*archiveFile // The top level of each archive read, keyed by the path of the archive
+
+	// Sizes for --dir-sizes keyed by Entry.path, directories have the total size of everything inside them
+	entrySizes map[string]uint64
+	// File categories recognized by --sniff, keyed by Entry.path
+	sniffedCategories map[string]string
+	// Found by LoadLastCommits(), keyed by Entry.path
+	lastCommits map[string]LastCommit
+	// The group number of each entry that shares its device and inode with another listed entry, keyed by Entry.path.
+	// Found by LoadHardlinkGroups()
+	hardlinkGroups map[string]int
+}
+
+func newFileCaches() *fileCaches {
+	return &fileCaches{
+		rootOfDirectory:      make(map[string]string),
+		statusOfRoot:         make(map[string]map[string]gogitstatus.ChangedFile),
+		detailedStatusOfRoot: make(map[string]map[string]GitDetailedStatus),
+		gitIgnore:            make(map[string]*ignore.GitIgnore),
+		gitExclude:           make(map[string]*ignore.GitIgnore),
+		gitIndex:             make(map[string]map[string]gogitstatus.GitIndexEntry),
+		packIndexes:          make(map[string][]*packIndex),
+		archives:             make(map[string]*archiveFile),
+		entrySizes:           make(map[string]uint64),
+		sniffedCategories:    make(map[string]string),
+		lastCommits:          make(map[string]LastCommit),
+		hardlinkGroups:       make(map[string]int),
+	}
+}
+
+var caches = newFileCaches()
+
+// Forgets everything cached about the files, so the next listing sees any changes
+func clearCaches() {
+	closePackFiles()
+	caches = newFileCaches()
+}
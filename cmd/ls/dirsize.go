@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Returns the size of the file, or the space allocated for it on disk if allocated is true
+func fileSize(stat fs.FileInfo, allocated bool) uint64 {
+	if allocated {
+		sysStat, ok := GetSysStat(stat)
+		if ok {
+			return sysStat.blocks * 512
+		}
+	}
+
+	return uint64(stat.Size())
+}
+
+// Returns the total size of everything in the directory at root, including itself.
+// Hard-linked files are only counted once. Files that can't be read are skipped
+func DirectorySize(root string, allocated bool) uint64 {
+	var total uint64
+	seen := make(map[fileID]bool)
+
+	myWalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		sysStat, ok := GetSysStat(stat)
+		if ok && sysStat.nlink > 1 && !stat.IsDir() {
+			id := fileID{sysStat.device, sysStat.inode}
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+		}
+
+		total += fileSize(stat, allocated)
+		return nil
+	})
+
+	return total
+}
+
+// Stores the size of each entry in caches.entrySizes, the directories are walked concurrently by a worker per CPU
+func ComputeEntrySizes(entries []Entry, allocated bool) {
+	var directories []Entry
+	for _, e := range entries {
+		if _, ok := caches.entrySizes[e.path]; ok {
+			continue
+		}
+
+		if e.IsDir() {
+			directories = append(directories, e)
+			continue
+		}
+
+		stat, err := e.Info()
+		if err == nil {
+			caches.entrySizes[e.path] = fileSize(stat, allocated)
+		}
+	}
+
+	var mutex sync.Mutex
+	parallelForEntries(directories, func(e Entry) {
+		size := DirectorySize(e.path, allocated)
+		mutex.Lock()
+		caches.entrySizes[e.path] = size
+		mutex.Unlock()
+	})
+}
+
+// Stores the size of everything below root in caches.entrySizes with a single walk, so --tree doesn't walk each level again.
+// Hard-linked files are only counted once in the whole tree
+func ComputeTreeSizes(root string, allocated bool) {
+	seen := make(map[fileID]bool)
+
+	myWalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		size := fileSize(stat, allocated)
+		sysStat, ok := GetSysStat(stat)
+		if ok && sysStat.nlink > 1 && !stat.IsDir() {
+			id := fileID{sysStat.device, sysStat.inode}
+			if seen[id] {
+				caches.entrySizes[path] = size
+				return nil
+			}
+			seen[id] = true
+		}
+
+		// Added to the entry itself and every directory above it, paths are built by myWalkDir like the tree builds them
+		for {
+			caches.entrySizes[path] += size
+			separator := strings.LastIndex(path, string(os.PathSeparator))
+			if path == root || separator == -1 {
+				break
+			}
+			path = path[:max(separator, 1)]
+		}
+		return nil
+	})
+}
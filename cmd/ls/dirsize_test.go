@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestComputeTreeSizes(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "a", "b"), 0o755)
+	os.WriteFile(filepath.Join(root, "a", "one"), make([]byte, 100), 0o644)
+	os.WriteFile(filepath.Join(root, "a", "b", "two"), make([]byte, 20), 0o644)
+	os.WriteFile(filepath.Join(root, "three"), make([]byte, 3), 0o644)
+	if runtime.GOOS != "windows" {
+		// Only counted once, in the first directory walked
+		os.Link(filepath.Join(root, "a", "one"), filepath.Join(root, "a", "b", "link"))
+	}
+	defer clearCaches()
+
+	directorySize := func(path string) uint64 {
+		stat, _ := os.Lstat(path)
+		return fileSize(stat, false)
+	}
+	linkSize := uint64(100)
+	if runtime.GOOS == "windows" {
+		linkSize = 0
+	}
+	aSize := directorySize(filepath.Join(root, "a"))
+	bSize := directorySize(filepath.Join(root, "a", "b"))
+
+	ComputeTreeSizes(root, false)
+
+	type TestCase struct {
+		path     string
+		expected uint64
+	}
+
+	tests := []TestCase{
+		{myJoin(root, "three"), 3},
+		{myJoin(myJoin(root, "a"), "one"), 100},
+		{myJoin(myJoin(root, "a"), "b"), bSize + 20 + linkSize},
+		{myJoin(root, "a"), aSize + bSize + 120},
+		{root, directorySize(root) + aSize + bSize + 123},
+	}
+	for _, test := range tests {
+		result := caches.entrySizes[test.path]
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.path, "but got", result)
+		}
+
+		// The same as walking each directory on its own
+		if result != DirectorySize(test.path, false) {
+			t.Fatal("Expected", DirectorySize(test.path, false), "like DirectorySize() for", test.path, "but got", result)
+		}
+	}
+}
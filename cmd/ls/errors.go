@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+var validErrorsValues = [...]string{
+	"text",
+	"json", // One JSON object per line on stderr
+}
+
+// Exit statuses like GNU ls
+const (
+	exitMinorProblem   = 1 // Like a subdirectory or an entry that couldn't be accessed
+	exitSeriousProblem = 2 // Like a file given as an argument that couldn't be accessed, or invalid options
+)
+
+// The highest exit status of the problems reported so far
+var exitStatus = 0
+
+// Where errors are written, replaced in tests
+var errorOutput io.Writer = os.Stderr
+
+// Names of the errno values most likely to come up while listing
+var errnoNames = map[syscall.Errno]string{
+	syscall.EACCES:       "EACCES",
+	syscall.EPERM:        "EPERM",
+	syscall.ENOENT:       "ENOENT",
+	syscall.ENOTDIR:      "ENOTDIR",
+	syscall.ELOOP:        "ELOOP",
+	syscall.ENAMETOOLONG: "ENAMETOOLONG",
+	syscall.EIO:          "EIO",
+	syscall.EMFILE:       "EMFILE",
+	syscall.ENFILE:       "ENFILE",
+	syscall.ENOMEM:       "ENOMEM",
+}
+
+// A problem with a file for --errors=json
+type JSONError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`         // Like "Failed to stat"
+	Error   string `json:"error"`           // Like "permission denied"
+	Errno   string `json:"errno,omitempty"` // Like "EACCES", empty if unknown
+}
+
+// Returns the text of the error without the operation and path Go adds, like "permission denied",
+// and the name of its errno like "EACCES" if known
+func describeError(err error) (text string, errno string) {
+	var pathError *fs.PathError
+	if errors.As(err, &pathError) {
+		err = pathError.Err
+	}
+
+	var errnoValue syscall.Errno
+	if errors.As(err, &errnoValue) {
+		errno = errnoNames[errnoValue]
+	}
+
+	return err.Error(), errno
+}
+
+// Reports a problem with the file at path like "Failed to stat 'x': no such file or directory (ENOENT)",
+// and raises the exit status to status. The listing keeps going
+func reportError(message string, path string, err error, status int, options Options) {
+	exitStatus = max(exitStatus, status)
+
+	text, errno := describeError(err)
+
+	if options.errorFormat == "json" {
+		json.NewEncoder(errorOutput).Encode(JSONError{Path: path, Message: message, Error: text, Errno: errno})
+		return
+	}
+
+	msg := message + " '" + path + "': " + text
+	if errno != "" {
+		msg += " (" + errno + ")"
+	}
+	printError(msg, options.colorEnabled)
+}
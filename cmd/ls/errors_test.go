@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// Options like the defaults of the command-line flags
+func testOptions() Options {
+	return Options{
+		sortBy:         "name",
+		groupBy:        "none",
+		quotingStyle:   "literal",
+		lineTerminator: "\n",
+		timeField:      "mtime",
+		timeStyle:      "default",
+		format:         "text",
+		errorFormat:    "text",
+	}
+}
+
+// Runs f and returns what it wrote to stdout and to errorOutput, and the exit status it set
+func captureOutput(t *testing.T, f func()) (stdout string, stderr string, status int) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errBuffer bytes.Buffer
+	oldStdout, oldErrorOutput := os.Stdout, errorOutput
+	os.Stdout, errorOutput = writer, &errBuffer
+	exitStatus = 0
+
+	defer func() {
+		os.Stdout, errorOutput = oldStdout, oldErrorOutput
+		exitStatus = 0
+	}()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		done <- string(data)
+	}()
+
+	f()
+	writer.Close()
+
+	return <-done, errBuffer.String(), exitStatus
+}
+
+func TestDescribeError(t *testing.T) {
+	type TestCase struct {
+		err           error
+		expectedText  string
+		expectedErrno string
+	}
+
+	tests := []TestCase{
+		{&fs.PathError{Op: "lstat", Path: "x", Err: syscall.ENOENT}, syscall.ENOENT.Error(), "ENOENT"},
+		{&fs.PathError{Op: "open", Path: "x", Err: syscall.EACCES}, syscall.EACCES.Error(), "EACCES"},
+		{syscall.ELOOP, syscall.ELOOP.Error(), "ELOOP"},
+		{errors.New("something else"), "something else", ""},
+	}
+	for _, test := range tests {
+		text, errno := describeError(test.err)
+		if text != test.expectedText || errno != test.expectedErrno {
+			t.Fatal("Expected", test.expectedText, test.expectedErrno, "for", test.err, "but got", text, errno)
+		}
+	}
+}
+
+func TestMissingArgument(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows errors have no errno names")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "file"), nil, 0o644)
+
+	missing := filepath.Join(dir, "missing")
+	var entries []Entry
+	_, stderr, status := captureOutput(t, func() {
+		entries, _ = CollectEntries([]string{missing, dir}, false, false, testOptions())
+	})
+
+	if status != exitSeriousProblem {
+		t.Fatal("Expected exit status", exitSeriousProblem, "but got", status)
+	}
+	if !strings.Contains(stderr, "Failed to stat '"+missing+"'") || !strings.Contains(stderr, "(ENOENT)") {
+		t.Fatal("Expected an ENOENT error for", missing, "but got", stderr)
+	}
+	if len(entries) != 1 || entries[0].Name() != "file" {
+		t.Fatal("Expected the other argument to still be listed, but got", entries)
+	}
+}
+
+func TestVanishedEntry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows errors have no errno names")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "kept"), nil, 0o644)
+	os.WriteFile(filepath.Join(dir, "vanished"), nil, 0o644)
+
+	options := testOptions()
+	stdout, stderr, status := captureOutput(t, func() {
+		entries, trees := CollectEntries([]string{dir}, false, false, options)
+		os.Remove(filepath.Join(dir, "vanished"))
+		PrintListing(entries, trees, options)
+	})
+
+	if status != exitMinorProblem {
+		t.Fatal("Expected exit status", exitMinorProblem, "but got", status)
+	}
+	if !strings.Contains(stderr, "vanished") || !strings.Contains(stderr, "(ENOENT)") {
+		t.Fatal("Expected an ENOENT error for the vanished entry, but got", stderr)
+	}
+	if stdout != "kept\n" {
+		t.Fatal("Expected the listing to keep going, but got", stdout)
+	}
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+
+	dir := t.TempDir()
+	loop := filepath.Join(dir, "loop")
+	os.Symlink("loop", loop)
+
+	_, stderr, status := captureOutput(t, func() {
+		CollectEntries([]string{filepath.Join(loop, "x")}, false, false, testOptions())
+	})
+
+	if status != exitSeriousProblem || !strings.Contains(stderr, "(ELOOP)") {
+		t.Fatal("Expected an ELOOP error with exit status", exitSeriousProblem, "but got", status, stderr)
+	}
+}
+
+func TestUnreadableDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits don't apply on Windows")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any directory")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "file"), nil, 0o644)
+	unreadable := filepath.Join(dir, "unreadable")
+	os.Mkdir(unreadable, 0o755)
+	os.WriteFile(filepath.Join(unreadable, "secret"), nil, 0o644)
+	os.Chmod(unreadable, 0)
+	defer os.Chmod(unreadable, 0o755)
+
+	options := testOptions()
+
+	// As an argument
+	_, stderr, status := captureOutput(t, func() {
+		CollectEntries([]string{unreadable}, false, false, options)
+	})
+	if status != exitSeriousProblem || !strings.Contains(stderr, "Failed to read directory '"+unreadable+"'") || !strings.Contains(stderr, "(EACCES)") {
+		t.Fatal("Expected an EACCES error with exit status", exitSeriousProblem, "but got", status, stderr)
+	}
+
+	// As a subdirectory with --recursive
+	stdout, stderr, status := captureOutput(t, func() {
+		entries, trees := CollectEntries([]string{dir}, false, true, options)
+		PrintListing(entries, trees, options)
+	})
+	if status != exitMinorProblem || !strings.Contains(stderr, "(EACCES)") {
+		t.Fatal("Expected an EACCES error with exit status", exitMinorProblem, "but got", status, stderr)
+	}
+	if !strings.Contains(stdout, "file\n") || strings.Contains(stdout, "secret") {
+		t.Fatal("Expected the readable entries to be listed, but got", stdout)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows errors have no errno names")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	options := testOptions()
+	options.errorFormat = "json"
+	_, stderr, _ := captureOutput(t, func() {
+		CollectEntries([]string{missing}, false, false, options)
+	})
+
+	var jsonError JSONError
+	if err := json.Unmarshal([]byte(stderr), &jsonError); err != nil {
+		t.Fatal("Expected a JSON object, but got", stderr)
+	}
+
+	expected := JSONError{Path: missing, Message: "Failed to stat", Error: syscall.ENOENT.Error(), Errno: "ENOENT"}
+	if jsonError != expected {
+		t.Fatal("Expected", expected, "but got", jsonError)
+	}
+}
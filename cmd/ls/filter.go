@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/kivattt/gogitstatus"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// A flag that can be given multiple times, like --ignore='*.o' --ignore='*.a'
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ", ")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// Returns true if name matches any of the glob patterns
+func matchesAnyGlob(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		matched, err := filepath.Match(pattern, name)
+		if err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if git would ignore the file at path, tracked files are never ignored
+func IsGitIgnored(path string, isDir bool) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	// Git never shows these
+	if filepath.Base(absPath) == ".git" {
+		return true
+	}
+
+	dir := filepath.Dir(absPath)
+	root := repositoryRootOf(dir)
+	if root == "" {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil || rel == "." {
+		return false
+	}
+
+	index, ok := caches.gitIndex[root]
+	if !ok {
+		gitDir, err := gitDirectory(root)
+		if err == nil {
+			index, _ = gogitstatus.ParseGitIndex(context.Background(), filepath.Join(gitDir, "index"))
+			exclude, err := ignore.CompileIgnoreFile(filepath.Join(gitDir, "info", "exclude"))
+			if err == nil {
+				caches.gitExclude[root] = exclude
+			}
+		}
+		caches.gitIndex[root] = index
+	}
+
+	if _, tracked := index[filepath.ToSlash(rel)]; tracked {
+		return false
+	}
+
+	// Patterns ending with '/' only match directories
+	matches := func(gitIgnore *ignore.GitIgnore, rel string) bool {
+		rel = filepath.ToSlash(rel)
+		if isDir {
+			rel += "/"
+		}
+		return gitIgnore.MatchesPath(rel)
+	}
+
+	if exclude := caches.gitExclude[root]; exclude != nil && matches(exclude, rel) {
+		return true
+	}
+
+	// Each .gitignore applies to paths relative to its own directory
+	for {
+		gitIgnore, ok := caches.gitIgnore[dir]
+		if !ok {
+			gitIgnore, _ = ignore.CompileIgnoreFile(filepath.Join(dir, ".gitignore"))
+			caches.gitIgnore[dir] = gitIgnore
+		}
+
+		if gitIgnore != nil {
+			relToDir, err := filepath.Rel(dir, absPath)
+			if err == nil && matches(gitIgnore, relToDir) {
+				return true
+			}
+		}
+
+		if dir == root {
+			return false
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// Returns true if the entry should not be listed because of --ignore, --hide, --only or --git-ignore
+func IsFiltered(e Entry, options Options) bool {
+	if matchesAnyGlob(e.Name(), options.ignorePatterns) {
+		return true
+	}
+
+	if !options.all && matchesAnyGlob(e.Name(), options.hidePatterns) {
+		return true
+	}
+
+	// Directories are kept so --recursive and --tree can still find matches inside them
+	if len(options.onlyPatterns) > 0 && !e.IsDir() && !matchesAnyGlob(e.Name(), options.onlyPatterns) {
+		return true
+	}
+
+	if options.gitIgnore && IsGitIgnored(e.path, e.IsDir()) {
+		return true
+	}
+
+	return false
+}
+
+// Returns the entries without those removed by --ignore, --hide, --only or --git-ignore
+func FilterEntries(entries []Entry, options Options) []Entry {
+	var ret []Entry
+	for _, e := range entries {
+		if !IsFiltered(e, options) {
+			ret = append(ret, e)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIsGitIgnored(t *testing.T) {
+	dir := newTestRepository(t, map[string]string{
+		".gitignore":          "*.log\nbuild/\n/rooted.txt\n",
+		"sub/.gitignore":      "*.tmp\n!keep.tmp\n",
+		"tracked.log":         "tracked files are never ignored",
+		"sub/deeper/x.go":     "package x",
+		"sub/deeper/.gitkeep": "",
+	})
+	// Ignored by *.log, so it has to be added by force
+	runGit(t, dir, "add", "-f", "tracked.log")
+	runGit(t, dir, "commit", "-qm", "tracked")
+	os.MkdirAll(filepath.Join(dir, "build"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "sub", "build"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".git", "info", "exclude"), []byte("excluded\n"), 0o644)
+	defer clearCaches()
+
+	type TestCase struct {
+		path     string
+		isDir    bool
+		expected bool
+	}
+
+	tests := []TestCase{
+		{"new.log", false, true},
+		{"tracked.log", false, false},
+		{"build", true, true},
+		{"build", false, false}, // "build/" only matches directories
+		{"sub/build", true, true},
+		{"rooted.txt", false, true},
+		{"sub/rooted.txt", false, false}, // Anchored to the directory of the .gitignore
+		{"sub/a.tmp", false, true},
+		{"sub/deeper/a.tmp", false, true}, // sub/.gitignore applies below sub too
+		{"sub/keep.tmp", false, false},
+		{"a.tmp", false, false}, // sub/.gitignore doesn't apply above sub
+		{"sub/deeper/x.go", false, false},
+		{"excluded", false, true},
+		{".git", true, true},
+	}
+	for _, test := range tests {
+		result := IsGitIgnored(filepath.Join(dir, filepath.FromSlash(test.path)), test.isDir)
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.path, "but got", result)
+		}
+	}
+
+	if IsGitIgnored(filepath.Join(t.TempDir(), "x.log"), false) {
+		t.Fatal("Expected nothing to be ignored outside of a repository")
+	}
+}
+
+func TestFilterEntries(t *testing.T) {
+	dir := newTestRepository(t, map[string]string{".gitignore": "*.o\n"})
+	for _, name := range []string{"main.c", "main.o", "notes.txt", "README", "backup~"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+	}
+	os.Mkdir(filepath.Join(dir, "src"), 0o755)
+	defer clearCaches()
+
+	type TestCase struct {
+		ignore    []string
+		hide      []string
+		only      []string
+		all       bool
+		gitIgnore bool
+		expected  []string
+	}
+
+	tests := []TestCase{
+		{nil, nil, nil, false, false, []string{".git", ".gitignore", "README", "backup~", "main.c", "main.o", "notes.txt", "src"}},
+		{[]string{"*~", "*.txt"}, nil, nil, false, false, []string{".git", ".gitignore", "README", "main.c", "main.o", "src"}},
+		// --hide is undone by --all, --ignore isn't
+		{nil, []string{"*~"}, nil, false, false, []string{".git", ".gitignore", "README", "main.c", "main.o", "notes.txt", "src"}},
+		{[]string{"*.txt"}, []string{"*~"}, nil, true, false, []string{".git", ".gitignore", "README", "backup~", "main.c", "main.o", "src"}},
+		// Directories are kept by --only
+		{nil, nil, []string{"*.c", "*.o"}, false, false, []string{".git", "main.c", "main.o", "src"}},
+		{nil, nil, nil, false, true, []string{".gitignore", "README", "backup~", "main.c", "notes.txt", "src"}},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.ignorePatterns = test.ignore
+		options.hidePatterns = test.hide
+		options.onlyPatterns = test.only
+		options.all = test.all
+		options.gitIgnore = test.gitIgnore
+
+		entries, _ := CollectEntries([]string{dir}, false, false, options)
+		names := entryNames(FilterEntries(entries, options))
+		slices.Sort(names)
+
+		if !slices.Equal(names, test.expected) {
+			t.Fatal("Expected", test.expected, "for", test, "but got", names)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The last commit that changed an entry, for --git-log
+type LastCommit struct {
+	hash   string
+	author string
+	date   time.Time // When it was authored
+}
+
+// The parts of a commit object needed to walk the history
+type gitCommit struct {
+	hash          string
+	tree          string
+	parents       []string
+	author        string
+	authorTime    time.Time
+	committerTime time.Time
+}
+
+// Parses the name and time of an "author" or "committer" line, like "Name <email> 1700000000 +0100"
+func parseSignature(signature string) (name string, t time.Time) {
+	name, rest, _ := strings.Cut(signature, " <")
+	_, rest, _ = strings.Cut(rest, "> ")
+
+	timestamp, offset, _ := strings.Cut(rest, " ")
+	seconds, _ := strconv.ParseInt(timestamp, 10, 64)
+	t = time.Unix(seconds, 0)
+
+	// Keep the timezone of the author, like git log does
+	if zone, err := time.Parse("-0700", offset); err == nil {
+		t = t.In(zone.Location())
+	}
+
+	return name, t
+}
+
+func ReadCommit(gitDir string, hash string) (gitCommit, error) {
+	objectType, data, err := ReadObject(gitDir, hash)
+	if err != nil {
+		return gitCommit{}, err
+	}
+	if objectType != "commit" {
+		return gitCommit{}, errors.New("not a commit: " + hash)
+	}
+
+	commit := gitCommit{hash: hash}
+
+	// The headers end at the first empty line, then comes the message
+	headers, _, _ := strings.Cut(string(data), "\n\n")
+	for _, line := range strings.Split(headers, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.tree = value
+		case "parent":
+			commit.parents = append(commit.parents, value)
+		case "author":
+			commit.author, commit.authorTime = parseSignature(value)
+		case "committer":
+			_, commit.committerTime = parseSignature(value)
+		}
+	}
+
+	return commit, nil
+}
+
+// Commits ordered newest first by commit time, like git log
+type commitQueue []gitCommit
+
+func (q commitQueue) Len() int           { return len(q) }
+func (q commitQueue) Less(i, j int) bool { return q[i].committerTime.After(q[j].committerTime) }
+func (q commitQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x any)        { *q = append(*q, x.(gitCommit)) }
+func (q *commitQueue) Pop() any {
+	old := *q
+	ret := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ret
+}
+
+// Returns the last commit that changed each of the paths (slash-separated and relative to the repository root).
+// The history is walked once from HEAD, following each path like git log -1 -- path does.
+// Paths that aren't in HEAD are left out, like untracked files
+func LastCommitsOf(gitDir string, paths []string) map[string]LastCommit {
+	ret := make(map[string]LastCommit)
+
+	headHash, err := ResolveRef(gitDir, "HEAD")
+	if err != nil {
+		return ret
+	}
+
+	head, err := ReadCommit(gitDir, headHash)
+	if err != nil {
+		return ret
+	}
+
+	trees := make(map[string]map[string]gitTreeEntry)
+
+	// Returns the hash of the file or directory at path in the tree, "" if it doesn't exist
+	lookup := func(treeHash string, path string) string {
+		hash := treeHash
+		for _, name := range strings.Split(path, "/") {
+			entries, ok := trees[hash]
+			if !ok {
+				entries, _ = ReadTree(gitDir, hash)
+				trees[hash] = entries
+			}
+
+			entry, ok := entries[name]
+			if !ok {
+				return ""
+			}
+			hash = entry.hash
+		}
+		return hash
+	}
+
+	remaining := make(map[string]bool)
+	for _, path := range paths {
+		if lookup(head.tree, path) != "" {
+			remaining[path] = true
+		}
+	}
+
+	// Like git log -- path, each path follows a single line of history: at a merge it only follows a parent
+	// with the same version (TREESAME), so changes the merge threw away aren't credited
+	commits := map[string]gitCommit{head.hash: head}
+	readCommit := func(hash string) (gitCommit, bool) {
+		commit, ok := commits[hash]
+		if !ok {
+			var err error
+			commit, err = ReadCommit(gitDir, hash)
+			if err != nil {
+				return gitCommit{}, false
+			}
+			commits[hash] = commit
+		}
+		return commit, true
+	}
+
+	// The paths each queued commit should be checked for, a commit is queued again if it gets more after being checked
+	pending := map[string]map[string]bool{head.hash: remaining}
+	queue := &commitQueue{head}
+
+	for queue.Len() > 0 {
+		commit := heap.Pop(queue).(gitCommit)
+		commitPaths := pending[commit.hash]
+		delete(pending, commit.hash)
+
+		// Parents missing from shallow clones are treated like there were none
+		var parents []gitCommit
+		for _, parentHash := range commit.parents {
+			if parent, ok := readCommit(parentHash); ok {
+				parents = append(parents, parent)
+			}
+		}
+
+		for path := range commitPaths {
+			hash := lookup(commit.tree, path)
+
+			sameParent := -1
+			for i, parent := range parents {
+				if parent.tree == commit.tree || lookup(parent.tree, path) == hash {
+					sameParent = i
+					break
+				}
+			}
+
+			if sameParent == -1 {
+				ret[path] = LastCommit{hash: commit.hash, author: commit.author, date: commit.authorTime}
+				continue
+			}
+
+			parent := parents[sameParent]
+			if pending[parent.hash] == nil {
+				pending[parent.hash] = make(map[string]bool)
+				heap.Push(queue, parent)
+			}
+			pending[parent.hash][path] = true
+		}
+	}
+
+	return ret
+}
+
+// Finds the last commit of each entry and stores it in caches.lastCommits.
+// Each repository's history is only walked once for all the entries in it, entries outside of repositories are skipped
+func LoadLastCommits(entries []Entry) {
+	defer closePackFiles()
+
+	pathsOfRoot := make(map[string]map[string][]string) // Entry paths keyed by repository root, then by path relative to the root
+
+	for _, e := range entries {
+		if _, ok := caches.lastCommits[e.path]; ok {
+			continue
+		}
+
+		absPath, err := filepath.Abs(e.path)
+		if err != nil {
+			continue
+		}
+
+		root := repositoryRootOf(filepath.Dir(absPath))
+		if root == "" {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, absPath)
+		if err != nil || rel == "." {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		if pathsOfRoot[root] == nil {
+			pathsOfRoot[root] = make(map[string][]string)
+		}
+		pathsOfRoot[root][rel] = append(pathsOfRoot[root][rel], e.path)
+	}
+
+	for root, entryPaths := range pathsOfRoot {
+		gitDir, err := gitDirectory(root)
+		if err != nil {
+			continue
+		}
+
+		var paths []string
+		for rel := range entryPaths {
+			paths = append(paths, rel)
+		}
+
+		for rel, lastCommit := range LastCommitsOf(gitDir, paths) {
+			for _, path := range entryPaths[rel] {
+				caches.lastCommits[path] = lastCommit
+			}
+		}
+	}
+}
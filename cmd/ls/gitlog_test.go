@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLastCommitsOfMerge(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	run := func(date string, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatal("git", args, "failed:", string(output))
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(name, content string) {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+	}
+
+	// The merge keeps the branch's version of f, so the newer change on main doesn't count
+	run("2020-01-01T00:00:00Z", "init", "-q", "-b", "main")
+	write("f", "base")
+	write("g", "base")
+	run("2020-01-01T00:00:00Z", "add", ".")
+	run("2020-01-01T00:00:00Z", "commit", "-qm", "base")
+	run("2020-01-02T00:00:00Z", "checkout", "-qb", "branch")
+	write("f", "branch")
+	run("2020-01-02T00:00:00Z", "commit", "-qam", "branch")
+	branchCommit := run("2020-01-02T00:00:00Z", "rev-parse", "HEAD")
+	run("2020-01-03T00:00:00Z", "checkout", "-q", "main")
+	write("f", "main")
+	write("g", "main")
+	run("2020-01-03T00:00:00Z", "commit", "-qam", "main")
+	mainCommit := run("2020-01-03T00:00:00Z", "rev-parse", "HEAD")
+	// Conflicts on f, resolved by keeping the branch's version
+	exec.Command("git", "-C", dir, "-c", "user.name=Test", "-c", "user.email=test@example.com", "merge", "-q", "branch").Run()
+	write("f", "branch")
+	run("2020-01-04T00:00:00Z", "commit", "-qam", "merge")
+
+	result := LastCommitsOf(filepath.Join(dir, ".git"), []string{"f", "g"})
+	if result["f"].hash != branchCommit {
+		t.Fatal("Expected", branchCommit, "for f but got", result["f"].hash)
+	}
+	if result["g"].hash != mainCommit {
+		t.Fatal("Expected", mainCommit, "for g but got", result["g"].hash)
+	}
+}
@@ -0,0 +1,568 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kivattt/gogitstatus"
+)
+
+// Just enough of reading Git objects to compare the index against HEAD.
+// Loose objects and packfiles (with deltas) are supported, SHA-256 repositories are not
+
+const gitHashLength = 20
+
+// Object types in packfiles
+const (
+	packObjectCommit   = 1
+	packObjectTree     = 2
+	packObjectBlob     = 3
+	packObjectTag      = 4
+	packObjectOfsDelta = 6
+	packObjectRefDelta = 7
+)
+
+var packObjectTypeNames = map[int]string{
+	packObjectCommit: "commit",
+	packObjectTree:   "tree",
+	packObjectBlob:   "blob",
+	packObjectTag:    "tag",
+}
+
+// An entry of a tree object
+type gitTreeEntry struct {
+	mode uint32
+	hash string // Hex
+}
+
+// A .idx file and its .pack file
+type packIndex struct {
+	packPath string
+	hashes   []byte // Sorted, gitHashLength bytes each
+	offsets  []uint64
+
+	pack       *os.File             // Opened when first needed
+	cache      map[int64]packObject // Objects read so far by offset, delta chains often share bases
+	cacheBytes int
+}
+
+type packObject struct {
+	typeNumber int
+	data       []byte
+}
+
+// Memory used for each packIndex.cache before it is emptied
+const packCacheLimit = 64 * 1024 * 1024
+
+// Returns the directory shared between worktrees, which has the objects and most refs
+func gitCommonDirectory(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+
+	commonDir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return commonDir
+}
+
+// Returns the hash that ref points to, like "HEAD" or "refs/heads/main"
+func ResolveRef(gitDir string, ref string) (string, error) {
+	commonDir := gitCommonDirectory(gitDir)
+
+	for depth := 0; depth < 10; depth++ {
+		// HEAD is per-worktree, the rest are shared
+		data, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref)))
+		if err != nil {
+			data, err = os.ReadFile(filepath.Join(commonDir, filepath.FromSlash(ref)))
+		}
+
+		if err != nil {
+			hash, found := findPackedRef(commonDir, ref)
+			if !found {
+				return "", errors.New("unable to resolve ref: " + ref)
+			}
+			return hash, nil
+		}
+
+		content := strings.TrimSpace(string(data))
+		symbolic, isSymbolic := strings.CutPrefix(content, "ref:")
+		if !isSymbolic {
+			return content, nil
+		}
+		ref = strings.TrimSpace(symbolic)
+	}
+
+	return "", errors.New("too many levels of symbolic refs")
+}
+
+// Looks up ref in the packed-refs file
+func findPackedRef(commonDir string, ref string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(commonDir, "packed-refs"))
+	if err != nil {
+		return "", false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+
+		hash, name, found := strings.Cut(line, " ")
+		if found && strings.TrimSpace(name) == ref {
+			return hash, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the type ("commit", "tree", "blob" or "tag") and the content of the object
+func ReadObject(gitDir string, hash string) (string, []byte, error) {
+	objectsDir := filepath.Join(gitCommonDirectory(gitDir), "objects")
+
+	if len(hash) != gitHashLength*2 {
+		return "", nil, errors.New("invalid object hash: " + hash)
+	}
+
+	file, err := os.Open(filepath.Join(objectsDir, hash[:2], hash[2:]))
+	if err == nil {
+		defer file.Close()
+		return readLooseObject(file)
+	}
+
+	rawHash, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, index := range packIndexes(objectsDir) {
+		offset, found := index.find(rawHash)
+		if found {
+			return index.readObject(objectsDir, offset)
+		}
+	}
+
+	return "", nil, errors.New("object not found: " + hash)
+}
+
+// Loose objects are a zlib-compressed "<type> <size>\0" header followed by the content
+func readLooseObject(file io.Reader) (string, []byte, error) {
+	reader, err := zlib.NewReader(file)
+	if err != nil {
+		return "", nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", nil, err
+	}
+
+	header, content, found := bytes.Cut(data, []byte{0})
+	if !found {
+		return "", nil, errors.New("invalid loose object header")
+	}
+
+	objectType, _, _ := strings.Cut(string(header), " ")
+	return objectType, content, nil
+}
+
+// Returns the (cached) pack indexes in the objects directory
+func packIndexes(objectsDir string) []*packIndex {
+	indexes, ok := caches.packIndexes[objectsDir]
+	if ok {
+		return indexes
+	}
+
+	idxPaths, _ := filepath.Glob(filepath.Join(objectsDir, "pack", "*.idx"))
+	for _, idxPath := range idxPaths {
+		index, err := readPackIndex(idxPath)
+		if err == nil {
+			indexes = append(indexes, index)
+		}
+	}
+
+	caches.packIndexes[objectsDir] = indexes
+	return indexes
+}
+
+// Reads a version 2 .idx file
+func readPackIndex(idxPath string) (*packIndex, error) {
+	data, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	const headerLength = 8 + 256*4
+	if len(data) < headerLength || !bytes.Equal(data[:4], []byte("\xfftOc")) || binary.BigEndian.Uint32(data[4:8]) != 2 {
+		return nil, errors.New("unsupported pack index: " + idxPath)
+	}
+
+	// The last fanout entry is the number of objects
+	count := int(binary.BigEndian.Uint32(data[headerLength-4 : headerLength]))
+
+	hashesStart := headerLength
+	crcStart := hashesStart + count*gitHashLength
+	offsetsStart := crcStart + count*4
+	largeOffsetsStart := offsetsStart + count*4
+	if len(data) < largeOffsetsStart {
+		return nil, errors.New("truncated pack index: " + idxPath)
+	}
+
+	index := &packIndex{
+		packPath: strings.TrimSuffix(idxPath, ".idx") + ".pack",
+		hashes:   data[hashesStart:crcStart],
+		offsets:  make([]uint64, count),
+	}
+
+	for i := 0; i < count; i++ {
+		offset := binary.BigEndian.Uint32(data[offsetsStart+i*4:])
+		if offset&0x80000000 == 0 {
+			index.offsets[i] = uint64(offset)
+			continue
+		}
+
+		// Offsets of 2GiB or more are in a separate table of 8-byte offsets
+		largeOffset := largeOffsetsStart + int(offset&0x7fffffff)*8
+		if len(data) < largeOffset+8 {
+			return nil, errors.New("truncated pack index: " + idxPath)
+		}
+		index.offsets[i] = binary.BigEndian.Uint64(data[largeOffset:])
+	}
+
+	return index, nil
+}
+
+// Returns the offset of the object in the pack
+func (index *packIndex) find(rawHash []byte) (uint64, bool) {
+	count := len(index.offsets)
+	i := sort.Search(count, func(i int) bool {
+		return bytes.Compare(index.hashes[i*gitHashLength:(i+1)*gitHashLength], rawHash) >= 0
+	})
+
+	if i < count && bytes.Equal(index.hashes[i*gitHashLength:(i+1)*gitHashLength], rawHash) {
+		return index.offsets[i], true
+	}
+	return 0, false
+}
+
+// Closes the pack file if it was opened, it is opened again when needed
+func (index *packIndex) Close() {
+	if index.pack != nil {
+		index.pack.Close()
+		index.pack = nil
+		index.cache = nil
+		index.cacheBytes = 0
+	}
+}
+
+// Closes the pack files opened by every cached pack index, the indexes themselves stay cached
+func closePackFiles() {
+	for _, indexes := range caches.packIndexes {
+		for _, index := range indexes {
+			index.Close()
+		}
+	}
+}
+
+func (index *packIndex) readObject(objectsDir string, offset uint64) (string, []byte, error) {
+	if index.pack == nil {
+		file, err := os.Open(index.packPath)
+		if err != nil {
+			return "", nil, err
+		}
+		index.pack = file
+		index.cache = make(map[int64]packObject)
+	}
+
+	typeNumber, data, err := index.readPackObject(objectsDir, int64(offset))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return packObjectTypeNames[typeNumber], data, nil
+}
+
+// Returns the type and content of the object at offset in the pack, resolving deltas
+func (index *packIndex) readPackObject(objectsDir string, offset int64) (int, []byte, error) {
+	if object, ok := index.cache[offset]; ok {
+		return object.typeNumber, object.data, nil
+	}
+
+	header := make([]byte, 32)
+	n, err := index.pack.ReadAt(header, offset)
+	if n == 0 {
+		return 0, nil, err
+	}
+	header = header[:n]
+
+	// The first byte has the type in bits 4-6, and the size continues in 7-bit groups while the high bit is set
+	typeNumber := int(header[0]>>4) & 0b111
+	i := 0
+	for header[i]&0x80 != 0 {
+		i++
+		if i >= len(header) {
+			return 0, nil, errors.New("invalid pack object header")
+		}
+	}
+	i++
+
+	var baseType int
+	var base []byte
+
+	switch typeNumber {
+	case packObjectOfsDelta:
+		// The base is at a relative offset earlier in the pack
+		if i >= len(header) {
+			return 0, nil, errors.New("invalid pack object header")
+		}
+		c := header[i]
+		relative := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			i++
+			if i >= len(header) {
+				return 0, nil, errors.New("invalid pack object header")
+			}
+			c = header[i]
+			relative = ((relative + 1) << 7) | int64(c&0x7f)
+		}
+		i++
+
+		baseType, base, err = index.readPackObject(objectsDir, offset-relative)
+		if err != nil {
+			return 0, nil, err
+		}
+	case packObjectRefDelta:
+		if len(header) < i+gitHashLength {
+			return 0, nil, errors.New("invalid pack object header")
+		}
+		baseHash := hex.EncodeToString(header[i : i+gitHashLength])
+		i += gitHashLength
+
+		var baseTypeName string
+		baseTypeName, base, err = ReadObject(filepath.Dir(objectsDir), baseHash)
+		if err != nil {
+			return 0, nil, err
+		}
+		for number, name := range packObjectTypeNames {
+			if name == baseTypeName {
+				baseType = number
+			}
+		}
+	}
+
+	reader, err := zlib.NewReader(io.NewSectionReader(index.pack, offset+int64(i), 1<<62))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if base != nil {
+		typeNumber = baseType
+		data, err = applyDelta(base, data)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if index.cacheBytes+len(data) > packCacheLimit {
+		index.cache = make(map[int64]packObject)
+		index.cacheBytes = 0
+	}
+	index.cache[offset] = packObject{typeNumber, data}
+	index.cacheBytes += len(data)
+
+	return typeNumber, data, nil
+}
+
+// Reads a little-endian size in 7-bit groups, returning it and the remaining bytes
+func readDeltaSize(delta []byte) (int, []byte) {
+	size := 0
+	shift := 0
+	for len(delta) > 0 {
+		c := delta[0]
+		delta = delta[1:]
+		size |= int(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			break
+		}
+	}
+
+	return size, delta
+}
+
+// Builds an object from its base and a delta of copy/insert instructions
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	baseSize, delta := readDeltaSize(delta)
+	if baseSize != len(base) {
+		return nil, errors.New("delta base size mismatch")
+	}
+
+	resultSize, delta := readDeltaSize(delta)
+	result := make([]byte, 0, resultSize)
+
+	for len(delta) > 0 {
+		instruction := delta[0]
+		delta = delta[1:]
+
+		if instruction&0x80 == 0 {
+			// Insert the next bytes as-is
+			n := int(instruction)
+			if n == 0 || n > len(delta) {
+				return nil, errors.New("invalid delta instruction")
+			}
+			result = append(result, delta[:n]...)
+			delta = delta[n:]
+			continue
+		}
+
+		// Copy from the base, bits 0-3 say which offset bytes follow, bits 4-6 which size bytes
+		var copyOffset, copySize int
+		for bit := 0; bit < 7; bit++ {
+			if instruction&(1<<bit) == 0 {
+				continue
+			}
+			if len(delta) == 0 {
+				return nil, errors.New("invalid delta instruction")
+			}
+
+			if bit < 4 {
+				copyOffset |= int(delta[0]) << (8 * bit)
+			} else {
+				copySize |= int(delta[0]) << (8 * (bit - 4))
+			}
+			delta = delta[1:]
+		}
+
+		if copySize == 0 {
+			copySize = 0x10000
+		}
+		if copyOffset+copySize > len(base) {
+			return nil, errors.New("invalid delta copy")
+		}
+		result = append(result, base[copyOffset:copyOffset+copySize]...)
+	}
+
+	if len(result) != resultSize {
+		return nil, errors.New("delta result size mismatch")
+	}
+	return result, nil
+}
+
+// Returns the hash of the tree of a commit
+func CommitTree(gitDir string, commitHash string) (string, error) {
+	objectType, data, err := ReadObject(gitDir, commitHash)
+	if err != nil {
+		return "", err
+	}
+	if objectType != "commit" {
+		return "", errors.New("not a commit: " + commitHash)
+	}
+
+	firstLine, _, _ := strings.Cut(string(data), "\n")
+	treeHash, found := strings.CutPrefix(firstLine, "tree ")
+	if !found {
+		return "", errors.New("commit has no tree: " + commitHash)
+	}
+
+	return treeHash, nil
+}
+
+// Returns the entries of a tree object keyed by name
+func ReadTree(gitDir string, treeHash string) (map[string]gitTreeEntry, error) {
+	objectType, data, err := ReadObject(gitDir, treeHash)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != "tree" {
+		return nil, errors.New("not a tree: " + treeHash)
+	}
+
+	// Each entry is "<octal mode> <name>\0<raw hash>"
+	ret := make(map[string]gitTreeEntry)
+	for len(data) > 0 {
+		modeAndName, rest, found := bytes.Cut(data, []byte{0})
+		if !found || len(rest) < gitHashLength {
+			return nil, errors.New("invalid tree: " + treeHash)
+		}
+
+		modeString, name, _ := strings.Cut(string(modeAndName), " ")
+		mode, err := strconv.ParseUint(modeString, 8, 32)
+		if err != nil {
+			return nil, errors.New("invalid tree: " + treeHash)
+		}
+
+		ret[name] = gitTreeEntry{mode: uint32(mode), hash: hex.EncodeToString(rest[:gitHashLength])}
+		data = rest[gitHashLength:]
+	}
+
+	return ret, nil
+}
+
+// Returns every file in the tree recursively, keyed by its slash-separated path like in .git/index
+func ReadTreeRecursive(gitDir string, treeHash string) (map[string]gitTreeEntry, error) {
+	ret := make(map[string]gitTreeEntry)
+
+	var readTree func(hash string, prefix string) error
+	readTree = func(hash string, prefix string) error {
+		entries, err := ReadTree(gitDir, hash)
+		if err != nil {
+			return err
+		}
+
+		for name, entry := range entries {
+			if entry.mode&gogitstatus.OBJECT_TYPE_MASK == gitTreeMode {
+				err = readTree(entry.hash, prefix+name+"/")
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			ret[prefix+name] = entry
+		}
+		return nil
+	}
+
+	err := readTree(treeHash, "")
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+const gitTreeMode = 0b0100 << 12 // Like the gogitstatus.REGULAR_FILE constants
+
+// Returns the files of the commit HEAD points to, an empty map if there are no commits yet
+func HeadFiles(gitDir string) (map[string]gitTreeEntry, error) {
+	head, err := ResolveRef(gitDir, "HEAD")
+	if err != nil {
+		// An unborn branch, like in a new repository
+		return make(map[string]gitTreeEntry), nil
+	}
+
+	treeHash, err := CommitTree(gitDir, head)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadTreeRecursive(gitDir, treeHash)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestApplyDelta(t *testing.T) {
+	type TestCase struct {
+		base     string
+		delta    string
+		expected string
+	}
+
+	tests := []TestCase{
+		// Base size 5, result size 5, copy 5 bytes from offset 0
+		{"hello", "\x05\x05\x90\x05", "hello"},
+		// Insert "hi " then copy 5 bytes from offset 0
+		{"hello", "\x05\x08\x03hi \x90\x05", "hi hello"},
+		// Copy 3 bytes from offset 2
+		{"hello", "\x05\x03\x91\x02\x03", "llo"},
+		// Insert only
+		{"", "\x00\x03\x03abc", "abc"},
+	}
+	for _, test := range tests {
+		result, err := applyDelta([]byte(test.base), []byte(test.delta))
+		if err != nil {
+			t.Fatal("Unexpected error applying delta to \""+test.base+"\":", err)
+		}
+		if string(result) != test.expected {
+			t.Fatal("Expected \"" + test.expected + "\" but got \"" + string(result) + "\"")
+		}
+	}
+
+	_, err := applyDelta([]byte("hello"), []byte("\x04\x05\x90\x05"))
+	if err == nil {
+		t.Fatal("Expected an error for a mismatched base size")
+	}
+}
+
+// Creates a repository with a few commits of a large file that changes a little each time, so repacking makes deltas
+func newTestRepositoryWithHistory(t *testing.T) string {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, "line "+strconv.Itoa(i))
+	}
+
+	dir := newTestRepository(t, map[string]string{
+		"big.txt":           strings.Join(lines, "\n"),
+		"sub/file.go":       "package sub",
+		"sub/deeper/x.json": "{}",
+		"script.sh":         "#!/bin/sh",
+	})
+	os.Chmod(filepath.Join(dir, "script.sh"), 0o755)
+	os.Symlink("big.txt", filepath.Join(dir, "link"))
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-qm", "mode and symlink")
+
+	for i := 0; i < 5; i++ {
+		lines[i*10] = "changed " + strconv.Itoa(i)
+		os.WriteFile(filepath.Join(dir, "big.txt"), []byte(strings.Join(lines, "\n")), 0o644)
+		runGit(t, dir, "commit", "-qam", "change "+strconv.Itoa(i))
+	}
+
+	return dir
+}
+
+// Checks the objects read against what git itself reads
+func checkGitObjects(t *testing.T, dir string, description string) {
+	clearCaches()
+	gitDir := filepath.Join(dir, ".git")
+
+	head, err := ResolveRef(gitDir, "HEAD")
+	if err != nil || head != runGit(t, dir, "rev-parse", "HEAD") {
+		t.Fatal(description+": expected HEAD to resolve to", runGit(t, dir, "rev-parse", "HEAD"), "but got", head, err)
+	}
+
+	main, err := ResolveRef(gitDir, "refs/heads/main")
+	if err != nil || main != head {
+		t.Fatal(description+": expected refs/heads/main to resolve to", head, "but got", main, err)
+	}
+
+	files, err := HeadFiles(gitDir)
+	if err != nil {
+		t.Fatal(description+":", err)
+	}
+
+	// Lines like "100644 blob <hash>\t<path>"
+	var expected, result []string
+	for _, line := range strings.Split(runGit(t, dir, "ls-tree", "-r", "HEAD"), "\n") {
+		modeTypeHash, path, _ := strings.Cut(line, "\t")
+		fields := strings.Fields(modeTypeHash)
+		expected = append(expected, fields[0]+" "+fields[2]+" "+path)
+	}
+	for path, entry := range files {
+		result = append(result, fmt.Sprintf("%06o %s %s", entry.mode, entry.hash, path))
+	}
+	slices.Sort(expected)
+	slices.Sort(result)
+	if !slices.Equal(expected, result) {
+		t.Fatal(description + ": expected the files\n" + strings.Join(expected, "\n") + "\nbut got\n" + strings.Join(result, "\n"))
+	}
+
+	// Every version of big.txt, most of them are deltas once packed
+	for i := 0; i <= 5; i++ {
+		revision := "HEAD~" + strconv.Itoa(i) + ":big.txt"
+		hash := runGit(t, dir, "rev-parse", revision)
+		objectType, data, err := ReadObject(gitDir, hash)
+		if err != nil || objectType != "blob" || string(data) != runGit(t, dir, "show", revision) {
+			t.Fatal(description+": wrong content for", revision, objectType, err)
+		}
+	}
+}
+
+func TestReadGitObjects(t *testing.T) {
+	dir := newTestRepositoryWithHistory(t)
+	defer clearCaches()
+
+	checkGitObjects(t, dir, "loose objects")
+
+	// So the test doesn't quietly only read whole objects
+	requireDeltas := func() {
+		idxPaths, _ := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.idx"))
+		if !strings.Contains(runGit(t, dir, append([]string{"verify-pack", "-v"}, idxPaths...)...), "chain length") {
+			t.Fatal("Expected the pack to have deltas")
+		}
+	}
+
+	runGit(t, dir, "repack", "-adf", "-q")
+	runGit(t, dir, "pack-refs", "--all")
+	if _, err := os.Stat(filepath.Join(dir, ".git", "refs", "heads", "main")); err == nil {
+		t.Fatal("Expected refs/heads/main to only be in packed-refs")
+	}
+	requireDeltas()
+	checkGitObjects(t, dir, "packed with offset deltas")
+
+	runGit(t, dir, "-c", "repack.useDeltaBaseOffset=false", "repack", "-adf", "-q")
+	requireDeltas()
+	checkGitObjects(t, dir, "packed with ref deltas")
+}
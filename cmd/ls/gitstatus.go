@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kivattt/gogitstatus"
+)
+
+// Returns the path to the .git directory of the repository with this root.
+// Worktrees and submodules have a .git file containing "gitdir: <path>" instead of a directory
+func gitDirectory(root string) (string, error) {
+	dotGitPath := filepath.Join(root, ".git")
+	stat, err := os.Stat(dotGitPath)
+	if err != nil {
+		return "", err
+	}
+
+	if stat.IsDir() {
+		return dotGitPath, nil
+	}
+
+	data, err := os.ReadFile(dotGitPath)
+	if err != nil {
+		return "", err
+	}
+
+	gitDir, found := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !found {
+		return "", errors.New("invalid .git file: " + dotGitPath)
+	}
+
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+
+	return gitDir, nil
+}
+
+// Returns the root of the git repository containing the directory dir, or an error if there is none
+func FindRepositoryRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		_, err := os.Stat(filepath.Join(dir, ".git"))
+		if err == nil {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("not a Git repository")
+		}
+		dir = parent
+	}
+}
+
+// Returns the changed/untracked files of the repository, including directories containing them, relative to root
+func RepositoryStatus(root string) (map[string]gogitstatus.ChangedFile, error) {
+	gitDir, err := gitDirectory(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	changedFiles, err := gogitstatus.StatusRaw(ctx, root, filepath.Join(gitDir, "index"), true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like gogitstatus.IncludingDirectories(), but a directory is only untracked if everything changed inside it is untracked
+	ret := maps.Clone(changedFiles)
+	for path, changedFile := range changedFiles {
+		for parent := filepath.Dir(path); parent != "."; parent = filepath.Dir(parent) {
+			directory, ok := ret[parent]
+			if !ok {
+				directory.Untracked = true
+			}
+			directory.WhatChanged |= changedFile.WhatChanged
+			directory.Untracked = directory.Untracked && changedFile.Untracked
+			ret[parent] = directory
+		}
+	}
+
+	return ret, nil
+}
+
+// The groups of --sort-by=git and --group-by=git, in order
+var gitGroupNames = [...]string{
+	"Untracked",
+	"Modified",
+	"Clean",
+}
+
+// Returns the index into gitGroupNames for the entry at path.
+// Staged and unstaged changes both count as modified, directories are grouped by the files inside them
+func gitGroupOf(detailedStatus map[string]GitDetailedStatus, path string) int {
+	status, ok := detailedStatus[path]
+	if !ok {
+		return 2
+	}
+	if status.staged || status.unstaged || status.nStaged > 0 || status.nModified > 0 {
+		return 1
+	}
+	if status.untracked || status.nUntracked > 0 {
+		return 0
+	}
+	return 2
+}
+
+// Like FindRepositoryRoot(), but cached. dir should be an absolute path. Returns "" if not in a repository
+func repositoryRootOf(dir string) string {
+	root, ok := caches.rootOfDirectory[dir]
+	if !ok {
+		root, _ = FindRepositoryRoot(dir)
+		caches.rootOfDirectory[dir] = root
+	}
+
+	return root
+}
+
+// Returns the changed/untracked entries keyed by Entry.path.
+// Each repository is only read once, even if entries span multiple paths or repositories
+func GitStatusOfEntries(entries []Entry) map[string]gogitstatus.ChangedFile {
+	ret := make(map[string]gogitstatus.ChangedFile)
+
+	for _, e := range entries {
+		absPath, err := filepath.Abs(e.path)
+		if err != nil {
+			continue
+		}
+
+		root := repositoryRootOf(filepath.Dir(absPath))
+		if root == "" {
+			continue
+		}
+
+		status, ok := caches.statusOfRoot[root]
+		if !ok {
+			status, _ = RepositoryStatus(root)
+			caches.statusOfRoot[root] = status
+		}
+
+		rel, err := filepath.Rel(root, absPath)
+		if err != nil || rel == "." {
+			continue
+		}
+
+		changedFile, ok := status[rel]
+		if ok {
+			ret[e.path] = changedFile
+		}
+	}
+
+	return ret
+}
+
+// The state of a file for --git-status-detailed, or the files inside a directory
+type GitDetailedStatus struct {
+	staged       bool   // The index differs from HEAD
+	stagedChange string // "added", "modified", "deleted" or "renamed"
+	renamedFrom  string // The path before a staged rename, relative to the repository root
+
+	unstaged    bool // The file differs from the index
+	whatChanged gogitstatus.WhatChanged
+	untracked   bool
+	ignored     bool
+
+	// For directories, the number of files inside with each state
+	nStaged    int
+	nModified  int
+	nUntracked int
+}
+
+// Returns the state of every changed/untracked file compared to both HEAD and the index, and the counts for each directory containing them.
+// Keyed by path relative to root
+func RepositoryDetailedStatus(root string) (map[string]GitDetailedStatus, error) {
+	defer closePackFiles()
+
+	gitDir, err := gitDirectory(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	indexPath := filepath.Join(gitDir, "index")
+	changedFiles, err := gogitstatus.StatusRaw(ctx, root, indexPath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// A new repository has no index until something is added
+	index, err := gogitstatus.ParseGitIndex(ctx, indexPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		index = make(map[string]gogitstatus.GitIndexEntry)
+	} else if err != nil {
+		return nil, err
+	}
+
+	head, err := HeadFiles(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]GitDetailedStatus)
+	for path, changedFile := range changedFiles {
+		status := ret[path]
+		status.untracked = changedFile.Untracked
+		status.unstaged = !changedFile.Untracked
+		status.whatChanged = changedFile.WhatChanged
+		ret[path] = status
+	}
+
+	// Staged changes are where the index differs from the tree of HEAD
+	var added []string
+	for indexPath, indexEntry := range index {
+		headEntry, inHead := head[indexPath]
+		hash := hex.EncodeToString(indexEntry.Hash)
+		if inHead && headEntry.hash == hash && headEntry.mode == indexEntry.Mode {
+			continue
+		}
+
+		path := filepath.FromSlash(indexPath)
+		status := ret[path]
+		status.staged = true
+		status.stagedChange = "modified"
+		if !inHead {
+			status.stagedChange = "added"
+			added = append(added, indexPath)
+		}
+		ret[path] = status
+	}
+
+	deletedWithHash := make(map[string]string)
+	for headPath, headEntry := range head {
+		if _, inIndex := index[headPath]; !inIndex {
+			deletedWithHash[headEntry.hash] = headPath
+		}
+	}
+
+	// An added file with the exact contents of a deleted one was renamed
+	for _, addedPath := range added {
+		hash := hex.EncodeToString(index[addedPath].Hash)
+		deletedPath, ok := deletedWithHash[hash]
+		if !ok {
+			continue
+		}
+		delete(deletedWithHash, hash)
+
+		path := filepath.FromSlash(addedPath)
+		status := ret[path]
+		status.stagedChange = "renamed"
+		status.renamedFrom = deletedPath
+		ret[path] = status
+	}
+
+	for _, deletedPath := range deletedWithHash {
+		path := filepath.FromSlash(deletedPath)
+		status := ret[path]
+		status.staged = true
+		status.stagedChange = "deleted"
+		ret[path] = status
+	}
+
+	// Each directory counts the files inside it
+	directories := make(map[string]GitDetailedStatus)
+	for path, status := range ret {
+		for parent := filepath.Dir(path); parent != "."; parent = filepath.Dir(parent) {
+			counts := directories[parent]
+			if status.staged {
+				counts.nStaged++
+			}
+			if status.unstaged {
+				counts.nModified++
+			}
+			if status.untracked {
+				counts.nUntracked++
+			}
+			directories[parent] = counts
+		}
+	}
+
+	for path, counts := range directories {
+		ret[path] = counts
+	}
+
+	return ret, nil
+}
+
+// Like GitStatusOfEntries(), but for --git-status-detailed which also includes staged files.
+// Ignored entries are included if showIgnored is true
+func GitDetailedStatusOfEntries(entries []Entry, showIgnored bool) map[string]GitDetailedStatus {
+	ret := make(map[string]GitDetailedStatus)
+
+	for _, e := range entries {
+		absPath, err := filepath.Abs(e.path)
+		if err != nil {
+			continue
+		}
+
+		root := repositoryRootOf(filepath.Dir(absPath))
+		if root == "" {
+			continue
+		}
+
+		status, ok := caches.detailedStatusOfRoot[root]
+		if !ok {
+			status, _ = RepositoryDetailedStatus(root)
+			caches.detailedStatusOfRoot[root] = status
+		}
+
+		rel, err := filepath.Rel(root, absPath)
+		if err != nil || rel == "." {
+			continue
+		}
+
+		detailedStatus, ok := status[rel]
+		if ok {
+			ret[e.path] = detailedStatus
+		} else if showIgnored && e.Name() != ".git" && IsGitIgnored(e.path, e.IsDir()) {
+			ret[e.path] = GitDetailedStatus{ignored: true}
+		}
+	}
+
+	return ret
+}
+
+var gitStateColors = map[string]string{
+	"staged":          "\x1b[0;32m",            // Green
+	"unstaged":        "\x1b[38;2;254;229;65m", // Yellow
+	"staged+unstaged": "\x1b[0;35m",            // Magenta
+	"untracked":       "\x1b[0;31m",            // Red
+	"ignored":         "\x1b[0;90m",            // Gray
+}
+
+// Returns the state as one of the keys in gitStateColors
+func (status GitDetailedStatus) State() string {
+	switch {
+	case status.ignored:
+		return "ignored"
+	case status.untracked:
+		return "untracked"
+	case status.staged && status.unstaged:
+		return "staged+unstaged"
+	case status.staged:
+		return "staged"
+	}
+	return "unstaged"
+}
+
+// Returns the unstaged changes as words, like "modified, mode changed"
+func describeWhatChanged(whatChanged gogitstatus.WhatChanged) string {
+	var ret []string
+	if whatChanged&gogitstatus.DELETED != 0 {
+		ret = append(ret, "deleted")
+	}
+	if whatChanged&gogitstatus.TYPE_CHANGED != 0 {
+		ret = append(ret, "type changed")
+	}
+	if whatChanged&gogitstatus.MODE_CHANGED != 0 {
+		ret = append(ret, "mode changed")
+	}
+	if whatChanged&gogitstatus.DATA_CHANGED != 0 || len(ret) == 0 {
+		ret = append(ret, "modified")
+	}
+
+	return strings.Join(ret, ", ")
+}
+
+// Returns the --git-status-detailed column, like "staged           renamed from 'old.txt'".
+// Directories get the number of files inside them instead, like "3 modified, 1 untracked"
+func FormatGitDetailedStatus(status GitDetailedStatus, isDir bool, options Options) string {
+	colorize := func(text string, state string) string {
+		if !options.colorEnabled {
+			return text
+		}
+		return gitStateColors[state] + text + "\x1b[0m"
+	}
+
+	if isDir && !status.ignored {
+		var counts []string
+		if status.nStaged > 0 {
+			counts = append(counts, colorize(strconv.Itoa(status.nStaged)+" staged", "staged"))
+		}
+		if status.nModified > 0 {
+			counts = append(counts, colorize(strconv.Itoa(status.nModified)+" modified", "unstaged"))
+		}
+		if status.nUntracked > 0 {
+			counts = append(counts, colorize(strconv.Itoa(status.nUntracked)+" untracked", "untracked"))
+		}
+		return strings.Join(counts, ", ")
+	}
+
+	state := status.State()
+	var description []string
+	if status.staged {
+		if status.renamedFrom != "" {
+			description = append(description, "renamed from "+QuoteName(filepath.FromSlash(status.renamedFrom), options.quotingStyle))
+		} else {
+			description = append(description, status.stagedChange)
+		}
+	}
+	if status.unstaged {
+		if status.staged {
+			description = append(description, describeWhatChanged(status.whatChanged)+" since staged")
+		} else {
+			description = append(description, describeWhatChanged(status.whatChanged))
+		}
+	}
+
+	if len(description) == 0 {
+		return colorize(state, state)
+	}
+
+	// "staged+unstaged" is the longest state
+	return colorize(state, state) + strings.Repeat(" ", 1+len("staged+unstaged")-len(state)) + strings.Join(description, ", ")
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Runs git in dir and returns its trimmed output
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatal("git", args, "failed:", string(output))
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Creates a repository in a new temporary directory with the files (keyed by slash-separated path) committed.
+// Skips the test if git isn't installed
+func newTestRepository(t *testing.T, files map[string]string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(content), 0o644)
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-qm", "initial")
+
+	return dir
+}
+
+func TestGroupByGit(t *testing.T) {
+	dir := newTestRepository(t, map[string]string{
+		"top.txt":         "top",
+		"clean.txt":       "clean",
+		"edited.txt":      "edited",
+		"sub/staged.txt":  "staged",
+		"cleandir/inside": "inside",
+	})
+	runGit(t, dir, "mv", "top.txt", "renamed.txt")
+	os.WriteFile(filepath.Join(dir, "edited.txt"), []byte("changed"), 0o644)
+	os.WriteFile(filepath.Join(dir, "sub", "staged.txt"), []byte("changed"), 0o644)
+	runGit(t, dir, "add", "sub/staged.txt")
+	os.WriteFile(filepath.Join(dir, "new.txt"), nil, 0o644)
+	os.Mkdir(filepath.Join(dir, "untracked"), 0o755)
+	os.WriteFile(filepath.Join(dir, "untracked", "file"), nil, 0o644)
+
+	options := testOptions()
+	options.groupBy = "git"
+	stdout, _, _ := captureOutput(t, func() {
+		entries, _ := CollectEntries([]string{dir}, false, false, options)
+		PrintGroupedEntries(OrderEntries(entries, options), options)
+	})
+
+	// Staged-only changes, like the rename and the directory with a staged file, are modified too
+	expected := "Untracked:\nnew.txt\nuntracked\n\nModified:\nedited.txt\nrenamed.txt\nsub\n\nClean:\nclean.txt\ncleandir\n"
+	if stdout != expected {
+		t.Fatal("Expected", strings.ReplaceAll(expected, "\n", "|"), "but got", strings.ReplaceAll(stdout, "\n", "|"))
+	}
+
+	entries, _ := CollectEntries([]string{dir}, false, false, options)
+	SortEntries(&entries, "git", "mtime", false)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	expectedOrder := "new.txt untracked edited.txt renamed.txt sub .git clean.txt cleandir"
+	if strings.Join(names, " ") != expectedOrder {
+		t.Fatal("Expected the order", expectedOrder, "but got", names)
+	}
+}
+
+func TestRepositoryDetailedStatus(t *testing.T) {
+	dir := newTestRepositoryWithHistory(t)
+	runGit(t, dir, "repack", "-adf", "-q")
+	runGit(t, dir, "pack-refs", "--all")
+
+	os.WriteFile(filepath.Join(dir, "sub", "file.go"), []byte("package changed"), 0o644)
+	runGit(t, dir, "add", "sub/file.go")
+	os.WriteFile(filepath.Join(dir, "added.txt"), []byte("added"), 0o644)
+	runGit(t, dir, "add", "added.txt")
+	runGit(t, dir, "rm", "-q", "script.sh")
+	runGit(t, dir, "mv", "big.txt", "moved.txt")
+	os.WriteFile(filepath.Join(dir, "sub", "deeper", "x.json"), []byte("[]"), 0o644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), nil, 0o644)
+
+	status, err := RepositoryDetailedStatus(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestCase struct {
+		path     string
+		expected GitDetailedStatus
+	}
+
+	tests := []TestCase{
+		{"sub/file.go", GitDetailedStatus{staged: true, stagedChange: "modified"}},
+		{"added.txt", GitDetailedStatus{staged: true, stagedChange: "added"}},
+		{"script.sh", GitDetailedStatus{staged: true, stagedChange: "deleted"}},
+		{"moved.txt", GitDetailedStatus{staged: true, stagedChange: "renamed", renamedFrom: "big.txt"}},
+		{"new.txt", GitDetailedStatus{untracked: true}},
+		{"sub", GitDetailedStatus{nStaged: 1, nModified: 1}},
+		{"sub/deeper", GitDetailedStatus{nModified: 1}},
+	}
+	for _, test := range tests {
+		result := status[filepath.FromSlash(test.path)]
+		result.whatChanged = 0
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.path, "but got", result)
+		}
+	}
+
+	unstaged := status[filepath.Join("sub", "deeper", "x.json")]
+	if !unstaged.unstaged || unstaged.staged || unstaged.whatChanged == 0 {
+		t.Fatal("Expected x.json to only have unstaged changes, but got", unstaged)
+	}
+
+	if _, ok := status["big.txt"]; ok {
+		t.Fatal("Expected the rename to not also show big.txt as deleted")
+	}
+
+	// A broken index is an error, not every file in HEAD being deleted
+	os.WriteFile(filepath.Join(dir, ".git", "index"), []byte("not an index"), 0o644)
+	if _, err := RepositoryDetailedStatus(dir); err == nil {
+		t.Fatal("Expected an error for a broken index")
+	}
+}
+
+func TestGitStatusOfEntries(t *testing.T) {
+	repoA := newTestRepository(t, map[string]string{"sub/changed.txt": "a", "sub/clean.txt": "a"})
+	os.WriteFile(filepath.Join(repoA, "sub", "changed.txt"), []byte("changed"), 0o644)
+	repoB := newTestRepository(t, map[string]string{"clean.txt": "b"})
+	os.WriteFile(filepath.Join(repoB, "untracked.txt"), nil, 0o644)
+
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+
+	type TestCase struct {
+		cwd      string
+		paths    []string
+		expected []string // The changed/untracked entry paths
+	}
+
+	tests := []TestCase{
+		// A subdirectory of a repository from outside of it
+		{filepath.Dir(repoA), []string{filepath.Join(repoA, "sub")}, []string{filepath.Join(repoA, "sub", "changed.txt")}},
+		// From inside a subdirectory
+		{filepath.Join(repoA, "sub"), []string{"."}, []string{"changed.txt"}},
+		// Two repositories at once
+		{filepath.Dir(repoA), []string{filepath.Join(repoA, "sub"), repoB}, []string{filepath.Join(repoA, "sub", "changed.txt"), filepath.Join(repoB, "untracked.txt")}},
+	}
+	for _, test := range tests {
+		clearCaches()
+		os.Chdir(test.cwd)
+
+		entries, _ := CollectEntries(test.paths, false, false, testOptions())
+		var result []string
+		for path := range GitStatusOfEntries(entries) {
+			result = append(result, path)
+		}
+		slices.Sort(result)
+
+		if !slices.Equal(result, test.expected) {
+			t.Fatal("Expected", test.expected, "for", test.paths, "in", test.cwd, "but got", result)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const gridColumnSeparator = "  "
+
+// Returns the length of str as shown in a terminal, ignoring ANSI escape codes like "\x1b[1;34m"
+// and OSC escape sequences like the hyperlinks from Hyperlink()
+func VisibleLength(str string) int {
+	length := 0
+	for i := 0; i < len(str); {
+		if str[i] == '\x1b' && i+1 < len(str) && str[i+1] == '[' {
+			// Skip until the final byte of the escape sequence
+			i += 2
+			for i < len(str) && (str[i] < 0x40 || str[i] > 0x7e) {
+				i++
+			}
+			i++
+			continue
+		}
+
+		if str[i] == '\x1b' && i+1 < len(str) && str[i+1] == ']' {
+			// Skip until the string terminator, either BEL or ESC \
+			i += 2
+			for i < len(str) && str[i] != '\a' && !(str[i] == '\x1b' && i+1 < len(str) && str[i+1] == '\\') {
+				i++
+			}
+			if i < len(str) && str[i] == '\x1b' {
+				i++
+			}
+			i++
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(str[i:])
+		i += size
+		length++
+	}
+
+	return length
+}
+
+// Returns the terminal width, or the COLUMNS environment variable if set.
+// ok is false if stdout is not a terminal and COLUMNS is not set
+func TerminalWidth() (width int, ok bool) {
+	columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err == nil && columns > 0 {
+		return columns, true
+	}
+
+	width, _, err = term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return 0, false
+	}
+
+	return width, true
+}
+
+// Returns the index into cells for the given row and column, or -1 if there is no cell there
+func gridIndex(nCells, nRows, nColumns, row, column int, across bool) int {
+	var index int
+	if across {
+		index = row*nColumns + column
+	} else {
+		index = column*nRows + row
+	}
+
+	if index >= nCells {
+		return -1
+	}
+	return index
+}
+
+// Returns the widest cell of each column for this layout
+func gridColumnWidths(cellLengths []int, nRows, nColumns int, across bool) []int {
+	widths := make([]int, nColumns)
+	for row := 0; row < nRows; row++ {
+		for column := 0; column < nColumns; column++ {
+			index := gridIndex(len(cellLengths), nRows, nColumns, row, column, across)
+			if index != -1 {
+				widths[column] = max(widths[column], cellLengths[index])
+			}
+		}
+	}
+
+	return widths
+}
+
+// Lays out cells in as many columns as fit within width, like GNU ls.
+// Cells are filled top-to-bottom (column-major), or left-to-right if across is true.
+// Returns the lines to print, without trailing newlines
+func GridLines(cells []string, width int, across bool) []string {
+	if len(cells) == 0 {
+		return nil
+	}
+
+	cellLengths := make([]int, len(cells))
+	for i, cell := range cells {
+		cellLengths[i] = VisibleLength(cell)
+	}
+
+	nRows := len(cells)
+	nColumns := 1
+	var widths []int
+
+	// Try the most columns first, no more than would fit if every cell was as narrow as the narrowest one
+	mostColumns := min(len(cells), max(1, (width+len(gridColumnSeparator))/(slices.Min(cellLengths)+len(gridColumnSeparator))))
+	for tryColumns := mostColumns; tryColumns >= 1; tryColumns-- {
+		tryRows := (len(cells) + tryColumns - 1) / tryColumns
+
+		// Column-major layouts with this many rows may leave trailing columns empty
+		if !across {
+			tryColumns = (len(cells) + tryRows - 1) / tryRows
+		}
+
+		tryWidths := gridColumnWidths(cellLengths, tryRows, tryColumns, across)
+		total := len(gridColumnSeparator) * (tryColumns - 1)
+		for _, w := range tryWidths {
+			total += w
+		}
+
+		if total <= width || tryColumns == 1 {
+			nRows = tryRows
+			nColumns = tryColumns
+			widths = tryWidths
+			break
+		}
+	}
+
+	lines := make([]string, nRows)
+	for row := 0; row < nRows; row++ {
+		var line strings.Builder
+		for column := 0; column < nColumns; column++ {
+			index := gridIndex(len(cells), nRows, nColumns, row, column, across)
+			if index == -1 {
+				break
+			}
+
+			line.WriteString(cells[index])
+
+			// No trailing spaces after the last cell in the row
+			nextIndex := gridIndex(len(cells), nRows, nColumns, row, column+1, across)
+			if column+1 < nColumns && nextIndex != -1 {
+				line.WriteString(strings.Repeat(" ", widths[column]-cellLengths[index]))
+				line.WriteString(gridColumnSeparator)
+			}
+		}
+		lines[row] = line.String()
+	}
+
+	return lines
+}
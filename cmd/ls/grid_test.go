@@ -0,0 +1,87 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestVisibleLength(t *testing.T) {
+	type TestCase struct {
+		str      string
+		expected int
+	}
+
+	tests := []TestCase{
+		{"", 0},
+		{"hello", 5},
+		{"\x1b[1;34mdir\x1b[0m", 3},
+		{"\x1b[38;2;255;105;180mvideo.mp4\x1b[0m", 9},
+		{"æøå", 3},
+		{"\x1b]8;;file://host/tmp/a\x1b\\a\x1b]8;;\x1b\\", 1},
+		{"\x1b]8;;file://host/tmp/a\aa\x1b]8;;\a", 1},
+	}
+	for _, test := range tests {
+		result := VisibleLength(test.str)
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.str, "but got", result)
+		}
+	}
+}
+
+func TestGridLines(t *testing.T) {
+	type TestCase struct {
+		cells    []string
+		width    int
+		across   bool
+		expected []string
+	}
+
+	tests := []TestCase{
+		{nil, 80, false, nil},
+		{[]string{"a", "b", "c"}, 80, false, []string{"a  b  c"}},
+		{[]string{"a", "b", "c"}, 1, false, []string{"a", "b", "c"}},
+		{[]string{"aaa", "b", "c", "d", "e"}, 9, false, []string{"aaa  c  e", "b    d"}},
+		{[]string{"aaa", "b", "c", "d", "e"}, 8, false, []string{"aaa  d", "b    e", "c"}},
+		{[]string{"aaa", "b", "c", "d", "e"}, 8, true, []string{"aaa  b", "c    d", "e"}},
+		{[]string{"\x1b[1;34maaa\x1b[0m", "b"}, 8, false, []string{"\x1b[1;34maaa\x1b[0m  b"}},
+	}
+	for _, test := range tests {
+		result := GridLines(test.cells, test.width, test.across)
+		if !slices.Equal(result, test.expected) {
+			t.Fatal("Expected:\n" + strings.Join(test.expected, "\n") + "\nbut got:\n" + strings.Join(result, "\n"))
+		}
+	}
+}
+
+// Many cells used to take seconds to lay out, since every number of columns was tried
+func TestGridLinesManyCells(t *testing.T) {
+	cells := make([]string, 30000)
+	for i := range cells {
+		cells[i] = "f" + strconv.Itoa(100000 + i)[1:] // Same width, so 10 columns fit in 80
+	}
+
+	for _, across := range []bool{false, true} {
+		lines := GridLines(cells, 80, across)
+		if len(lines) != 3000 {
+			t.Fatal("Expected 3000 lines of 10 columns but got", len(lines))
+		}
+		for _, line := range lines {
+			if VisibleLength(line) > 80 {
+				t.Fatal("Expected lines to fit within 80 columns but got", line)
+			}
+		}
+	}
+}
+
+func BenchmarkGridLines(b *testing.B) {
+	cells := make([]string, 30000)
+	for i := range cells {
+		cells[i] = "file" + strconv.Itoa(i)
+	}
+
+	for i := 0; i < b.N; i++ {
+		GridLines(cells, 80, true)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+)
+
+// Colors of the --group-hardlinks tags, used in turn so neighbouring groups are easy to tell apart
+var hardlinkTagColors = [...]string{
+	"\x1b[0;36m", // Cyan
+	"\x1b[0;35m", // Magenta
+	"\x1b[0;33m", // Yellow
+	"\x1b[0;32m", // Green
+	"\x1b[0;34m", // Blue
+}
+
+// Finds the entries that are hard links to the same file, and numbers each group starting from 1.
+// Groups are numbered in the order of their first entry by path, so the numbers don't change between runs
+func LoadHardlinkGroups(entries []Entry) {
+	pathsOfFile := make(map[fileID][]string)
+	for _, e := range entries {
+		// Directories can't be hard linked, "." and ".." aren't listed
+		if e.IsDir() {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		sysStat, ok := GetSysStat(info)
+		if !ok || sysStat.nlink < 2 {
+			continue
+		}
+
+		id := fileID{sysStat.device, sysStat.inode}
+		if !slices.Contains(pathsOfFile[id], e.path) {
+			pathsOfFile[id] = append(pathsOfFile[id], e.path)
+		}
+	}
+
+	var groups [][]string
+	for _, paths := range pathsOfFile {
+		if len(paths) > 1 {
+			slices.Sort(paths)
+			groups = append(groups, paths)
+		}
+	}
+	slices.SortFunc(groups, func(a, b []string) int { return slices.Compare(a, b) })
+
+	for i, paths := range groups {
+		for _, path := range paths {
+			caches.hardlinkGroups[path] = i + 1
+		}
+	}
+}
+
+// Returns the --group-hardlinks tag of a group, like "[link 1]"
+func FormatHardlinkTag(group int, colorEnabled bool) string {
+	tag := "[link " + strconv.Itoa(group) + "]"
+	if !colorEnabled {
+		return tag
+	}
+
+	return hardlinkTagColors[(group-1)%len(hardlinkTagColors)] + tag + "\x1b[0m"
+}
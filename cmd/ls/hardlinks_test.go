@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLoadHardlinkGroups(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inode numbers aren't available on Windows")
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "lonely"} {
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644)
+	}
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	// "b" is linked to first, but "a" comes first by path, so its group is 1
+	for _, link := range [][2]string{{"b", "z-b"}, {"a", "y-a"}, {"a", filepath.Join("sub", "a")}} {
+		if err := os.Link(filepath.Join(dir, link[0]), filepath.Join(dir, link[1])); err != nil {
+			t.Skip("hard links aren't supported:", err)
+		}
+	}
+	defer clearCaches()
+
+	entries, _ := CollectEntries([]string{dir}, false, false, testOptions())
+	subEntries, _ := CollectEntries([]string{filepath.Join(dir, "sub")}, false, false, testOptions())
+	LoadHardlinkGroups(append(entries, subEntries...))
+
+	type TestCase struct {
+		path     string
+		expected int // 0 for no group
+	}
+
+	tests := []TestCase{
+		{"a", 1},
+		{"y-a", 1},
+		{filepath.Join("sub", "a"), 1},
+		{"b", 2},
+		{"z-b", 2},
+		{"lonely", 0},
+		{"sub", 0},
+	}
+	for _, test := range tests {
+		result := caches.hardlinkGroups[filepath.Join(dir, test.path)]
+		if result != test.expected {
+			t.Fatal("Expected the group", test.expected, "for", test.path, "but got", result)
+		}
+	}
+
+	// A file linked from outside of the listing isn't grouped with anything
+	clearCaches()
+	subEntries, _ = CollectEntries([]string{filepath.Join(dir, "sub")}, false, false, testOptions())
+	LoadHardlinkGroups(subEntries)
+	if group, ok := caches.hardlinkGroups[filepath.Join(dir, "sub", "a")]; ok {
+		t.Fatal("Expected no group for a file without other links in the listing, but got", group)
+	}
+}
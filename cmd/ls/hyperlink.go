@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+var hostname, _ = os.Hostname()
+
+// Wraps text in an OSC 8 escape sequence, which terminals show as a clickable link to the file at path
+// https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda
+func Hyperlink(text string, path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return text
+	}
+
+	fileURL := url.URL{
+		Scheme: "file",
+		Host:   hostname,
+		Path:   filepath.ToSlash(absPath),
+	}
+
+	return "\x1b]8;;" + fileURL.String() + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
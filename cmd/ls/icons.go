@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// Nerd Font glyphs for --icons, keyed like the colors map (see FileCategory()).
+// Overridable in the theme file with e.g. "icon.directory=<glyph>"
+var icons = map[string]string{
+	"nothing":               "\uf15b", // nf-fa-file
+	"directory":             "\uf07b", // nf-fa-folder
+	"sticky":                "\uf07b",
+	"other_writable":        "\uf07b",
+	"sticky_other_writable": "\uf07b",
+	"executable":            "\uf489", // nf-oct-terminal
+	"setuid":                "\uf489",
+	"setgid":                "\uf489",
+	"symlink":               "\uf481", // nf-oct-file_symlink_file
+	"symlink_directory":     "\uf482", // nf-oct-file_symlink_directory
+	"symlink_broken":        "\uf127", // nf-fa-chain_broken
+	"missing":               "\uf127",
+	"pipe":                  "\uf0ec", // nf-fa-exchange
+	"socket":                "\uf1e6", // nf-fa-plug
+	"block_device":          "\uf0a0", // nf-fa-hdd_o
+	"char_device":           "\uf0a0",
+	"image":                 "\uf1c5", // nf-fa-file_image_o
+	"video":                 "\uf1c8", // nf-fa-file_video_o
+	"audio":                 "\uf1c7", // nf-fa-file_audio_o
+	"archive":               "\uf1c6", // nf-fa-file_archive_o
+	"code":                  "\uf121", // nf-fa-code
+	"document":              "\uf15c", // nf-fa-file_text
+}
+
+// Glyphs for programming languages and other well-known files, keyed by the lowercase suffix.
+// Overridable in the theme file with e.g. "icon.*.go=<glyph>"
+var extensionIcons = map[string]string{
+	".go":         "\ue627", // nf-seti-go
+	".rs":         "\ue7a8", // nf-dev-rust
+	".py":         "\ue606", // nf-seti-python
+	".js":         "\ue74e", // nf-dev-javascript
+	".ts":         "\ue628", // nf-seti-typescript
+	".c":          "\ue61e", // nf-custom-c
+	".h":          "\ue61e",
+	".cpp":        "\ue61d", // nf-custom-cpp
+	".hpp":        "\ue61d",
+	".java":       "\ue738", // nf-dev-java
+	".kt":         "\ue634", // nf-seti-kotlin
+	".rb":         "\ue739", // nf-dev-ruby
+	".php":        "\ue73d", // nf-dev-php
+	".lua":        "\ue620", // nf-seti-lua
+	".swift":      "\ue755", // nf-dev-swift
+	".hs":         "\ue777", // nf-dev-haskell
+	".sh":         "\uf489", // nf-oct-terminal
+	".vim":        "\ue62b", // nf-custom-vim
+	".html":       "\ue736", // nf-dev-html5
+	".css":        "\ue749", // nf-dev-css3
+	".json":       "\ue60b", // nf-seti-json
+	".md":         "\uf48a", // nf-oct-markdown
+	"go.mod":      "\ue627",
+	"go.sum":      "\ue627",
+	"makefile":    "\uf489",
+	"dockerfile":  "\uf308", // nf-linux-docker
+	".gitignore":  "\ue702", // nf-dev-git
+	".gitmodules": "\ue702",
+}
+
+// Applies a theme file rule like "icon.directory=<glyph>" or "icon.*.go=<glyph>", key is without "icon."
+func applyIconRule(key, value string) error {
+	if value == "" {
+		return errors.New("empty icon for \"" + key + "\"")
+	}
+
+	if suffix, ok := strings.CutPrefix(key, "*"); ok {
+		if suffix == "" {
+			return errors.New("empty suffix in \"icon." + key + "\"")
+		}
+		extensionIcons[strings.ToLower(suffix)] = value
+		return nil
+	}
+
+	if _, ok := icons[key]; !ok {
+		return errors.New("unknown file type \"" + key + "\" for icon")
+	}
+	icons[key] = value
+	return nil
+}
+
+// Returns the icon for the file, regular files use the icon of the longest matching suffix if any
+func FileIcon(stat os.FileInfo, path string) string {
+	category := FileCategory(stat, path)
+
+	if stat != nil && stat.Mode().IsRegular() && category != "executable" && category != "setuid" && category != "setgid" {
+		lower := strings.ToLower(path)
+		longest := -1
+		var ret string
+		for suffix, icon := range extensionIcons {
+			if len(suffix) > longest && strings.HasSuffix(lower, suffix) {
+				ret = icon
+				longest = len(suffix)
+			}
+		}
+
+		if longest != -1 {
+			return ret
+		}
+	}
+
+	icon, ok := icons[category]
+	if !ok {
+		return icons["nothing"]
+	}
+	return icon
+}
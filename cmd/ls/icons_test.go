@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestFileIcon(t *testing.T) {
+	defer saveColors()()
+	if err := applyIconRule("*.tmp", "T"); err != nil {
+		t.Fatal(err)
+	}
+
+	type TestCase struct {
+		name     string
+		mode     fs.FileMode
+		expected string
+	}
+
+	tests := []TestCase{
+		{"main.go", 0o644, extensionIcons[".go"]},
+		{"MAIN.GO", 0o644, extensionIcons[".go"]},
+		{"go.mod", 0o644, extensionIcons["go.mod"]},
+		{"Makefile", 0o644, extensionIcons["makefile"]},
+		{"x.tmp", 0o644, "T"},
+		{"notes", 0o644, icons["nothing"]},
+		{"photo.png", 0o644, icons["image"]},
+		{"sub", fs.ModeDir | 0o755, icons["directory"]},
+		{"sub.go", fs.ModeDir | 0o755, icons["directory"]}, // Suffixes only apply to files
+		{"fifo", fs.ModeNamedPipe | 0o644, icons["pipe"]},
+		{"build.go", 0o755, icons["executable"]}, // Executables keep their icon, whatever the suffix
+	}
+	for _, test := range tests {
+		result := FileIcon(&archiveFile{name: test.name, mode: test.mode}, test.name)
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.name, "but got", result)
+		}
+	}
+
+	type ErrorTestCase struct {
+		key   string
+		value string
+	}
+
+	errorTests := []ErrorTestCase{
+		{"directory", ""},
+		{"*", "x"},
+		{"nope", "x"},
+	}
+	for _, test := range errorTests {
+		if err := applyIconRule(test.key, test.value); err == nil {
+			t.Fatal("Expected an error for", test.key+"="+test.value)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kivattt/gogitstatus"
+)
+
+var validFormatValues = [...]string{
+	"text",
+	"json",
+	"ndjson",
+}
+
+// An entry in --format=json or --format=ndjson
+type JSONEntry struct {
+	Name          string         `json:"name"`
+	Path          string         `json:"path"`
+	Type          string         `json:"type"`
+	Mode          string         `json:"mode"`
+	Size          int64          `json:"size"`
+	Modified      string         `json:"mtime"`
+	SymlinkTarget string         `json:"symlink_target,omitempty"`
+	Category      string         `json:"category"`             // The key in the colors map, see FileCategory()
+	GitStatus     *JSONGitStatus `json:"git_status,omitempty"` // Only for changed/untracked entries when --git-status is used
+}
+
+type JSONGitStatus struct {
+	WhatChanged []string `json:"what_changed"`
+	Untracked   bool     `json:"untracked"`
+}
+
+// Collected until the end of the program for --format=json, since it is a single array
+var jsonEntries = []JSONEntry{}
+
+// Returns the type of file as a word, e.g. "directory" or "symlink"
+func FileTypeName(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode.IsRegular():
+		return "file"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode&fs.ModeNamedPipe != 0:
+		return "pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeCharDevice != 0:
+		return "char_device"
+	case mode&fs.ModeDevice != 0:
+		return "block_device"
+	}
+
+	return "unknown"
+}
+
+func NewJSONEntry(e Entry, stat os.FileInfo, changedFile gogitstatus.ChangedFile, changed bool) JSONEntry {
+	ret := JSONEntry{
+		Name:     e.Name(),
+		Path:     e.path,
+		Type:     FileTypeName(stat.Mode()),
+		Mode:     ModeString(stat.Mode()),
+		Size:     stat.Size(),
+		Modified: stat.ModTime().Format(time.RFC3339Nano),
+		Category: FileCategory(stat, e.path),
+	}
+
+	if stat.Mode()&fs.ModeSymlink != 0 {
+		ret.SymlinkTarget, _, _ = SymlinkTarget(e.path)
+	}
+
+	if changed {
+		// WhatChangedToString() has no particular order
+		whatChanged := []string{}
+		if changedFile.WhatChanged != 0 {
+			whatChanged = strings.Split(gogitstatus.WhatChangedToString(changedFile.WhatChanged), ",")
+			slices.Sort(whatChanged)
+		}
+
+		ret.GitStatus = &JSONGitStatus{
+			WhatChanged: whatChanged,
+			Untracked:   changedFile.Untracked,
+		}
+	}
+
+	return ret
+}
+
+// Prints each entry as a line of JSON for --format=ndjson, or collects them for --format=json
+func PrintEntriesJSON(entries []Entry, changedOrUntracked map[string]gogitstatus.ChangedFile, options Options) {
+	encoder := json.NewEncoder(os.Stdout)
+
+	for _, e := range entries {
+		if !options.all && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			reportError("Failed to stat", e.path, err, exitMinorProblem, options)
+			continue
+		}
+
+		changedFile, changed := changedOrUntracked[e.path]
+		jsonEntry := NewJSONEntry(e, info, changedFile, changed)
+
+		if options.format == "ndjson" {
+			encoder.Encode(jsonEntry)
+		} else {
+			jsonEntries = append(jsonEntries, jsonEntry)
+		}
+	}
+}
+
+// Prints the entries collected for --format=json
+func FlushJSON() {
+	data, err := json.MarshalIndent(jsonEntries, "", "  ")
+	if err != nil {
+		return
+	}
+
+	os.Stdout.Write(data)
+	os.Stdout.WriteString("\n")
+}
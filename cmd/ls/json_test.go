@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONOutput(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644)
+	os.Chmod(filepath.Join(dir, "main.go"), 0o644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0o644)
+	modTime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(filepath.Join(dir, "main.go"), modTime, modTime)
+	if runtime.GOOS != "windows" {
+		os.Symlink("main.go", filepath.Join(dir, "link"))
+	}
+
+	options := testOptions()
+	options.format = "ndjson"
+	stdout, _, _ := captureOutput(t, func() {
+		entries, trees := CollectEntries([]string{dir}, false, false, options)
+		PrintListing(OrderEntries(entries, options), trees, options)
+	})
+
+	var result []JSONEntry
+	for _, line := range strings.Split(strings.TrimSuffix(stdout, "\n"), "\n") {
+		var entry JSONEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal("Expected a JSON object on each line, but got", line)
+		}
+		result = append(result, entry)
+	}
+
+	tests := []JSONEntry{
+		{Name: "main.go", Path: filepath.Join(dir, "main.go"), Type: "file", Mode: "-rw-r--r--", Size: 12, Modified: modTime.Local().Format(time.RFC3339Nano), Category: "code"},
+		{Name: "sub", Path: filepath.Join(dir, "sub"), Type: "directory", Category: "directory"},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, JSONEntry{Name: "link", Path: filepath.Join(dir, "link"), Type: "symlink", SymlinkTarget: "main.go", Category: "symlink"})
+	}
+	if len(result) != len(tests) {
+		t.Fatal("Expected", len(tests), "entries without the hidden file, but got", result)
+	}
+
+	for _, expected := range tests {
+		var found *JSONEntry
+		for i := range result {
+			if result[i].Name == expected.Name {
+				found = &result[i]
+			}
+		}
+		if found == nil {
+			t.Fatal("Expected an entry for", expected.Name, "but got", result)
+		}
+
+		// Only compared for main.go, the rest depend on the file system
+		got := *found
+		if expected.Name != "main.go" {
+			got.Mode, got.Size, got.Modified = "", 0, ""
+		}
+		if runtime.GOOS == "windows" {
+			got.Mode, expected.Mode = "", ""
+		}
+		if got != expected {
+			t.Fatal("Expected", expected, "but got", got)
+		}
+	}
+
+	// --format=json is one array of everything listed
+	jsonEntries = []JSONEntry{}
+	defer func() { jsonEntries = []JSONEntry{} }()
+	options.format = "json"
+	stdout, _, _ = captureOutput(t, func() {
+		entries, trees := CollectEntries([]string{dir}, false, false, options)
+		PrintListing(OrderEntries(entries, options), trees, options)
+		FlushJSON()
+	})
+
+	var array []JSONEntry
+	if err := json.Unmarshal([]byte(stdout), &array); err != nil || len(array) != len(tests) {
+		t.Fatal("Expected an array of", len(tests), "entries, but got", stdout)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+)
+
+var errXattrsUnsupported = errors.New("extended attributes are only supported on Linux")
+
+func ListXattrs(path string) ([]string, error) {
+	return nil, errXattrsUnsupported
+}
+
+func GetXattr(path string, name string) ([]byte, error) {
+	return nil, errXattrsUnsupported
+}
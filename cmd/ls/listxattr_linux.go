@@ -0,0 +1,58 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// Returns the names of the extended attributes of the file, without following symlinks
+func ListXattrs(path string) ([]string, error) {
+	for {
+		size, err := unix.Llistxattr(path, nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+
+		// The list is "name\0name\0..."
+		buffer := make([]byte, size)
+		size, err = unix.Llistxattr(path, buffer)
+		if err == unix.ERANGE {
+			continue // Attributes were added in-between
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var ret []string
+		for _, name := range bytes.Split(buffer[:size], []byte{0}) {
+			if len(name) > 0 {
+				ret = append(ret, string(name))
+			}
+		}
+		return ret, nil
+	}
+}
+
+// Returns the value of an extended attribute of the file, without following symlinks
+func GetXattr(path string, name string) ([]byte, error) {
+	for {
+		size, err := unix.Lgetxattr(path, name, nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+
+		buffer := make([]byte, size)
+		size, err = unix.Lgetxattr(path, name, buffer)
+		if err == unix.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return buffer[:size], nil
+	}
+}
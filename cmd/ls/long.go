@@ -0,0 +1,243 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata not available through os.FileInfo, see GetSysStat()
+type SysStat struct {
+	uid      uint32
+	gid      uint32
+	nlink    uint64
+	device   uint64
+	inode    uint64
+	blocks   uint64    // Allocated 512-byte blocks
+	rdev     uint64    // The device of a block or character device file
+	accessed time.Time // atime
+	changed  time.Time // ctime, when the metadata last changed
+}
+
+// Formats the mode like GNU ls, e.g. "drwxr-xr-x" or "-rwsr-xr-x"
+func ModeString(mode fs.FileMode) string {
+	var ret [10]byte
+
+	switch {
+	case mode.IsDir():
+		ret[0] = 'd'
+	case mode&fs.ModeSymlink != 0:
+		ret[0] = 'l'
+	case mode&fs.ModeNamedPipe != 0:
+		ret[0] = 'p'
+	case mode&fs.ModeSocket != 0:
+		ret[0] = 's'
+	case mode&fs.ModeCharDevice != 0:
+		ret[0] = 'c'
+	case mode&fs.ModeDevice != 0:
+		ret[0] = 'b'
+	default:
+		ret[0] = '-'
+	}
+
+	const rwx = "rwxrwxrwx"
+	perm := mode.Perm()
+	for i := 0; i < 9; i++ {
+		if perm&(1<<uint(8-i)) != 0 {
+			ret[1+i] = rwx[i]
+		} else {
+			ret[1+i] = '-'
+		}
+	}
+
+	// The special bits replace the executable bit of their column, uppercase if it wasn't executable
+	special := func(index int, set bool, char byte) {
+		if !set {
+			return
+		}
+
+		if ret[index] == 'x' {
+			ret[index] = char
+		} else {
+			ret[index] = char - 'a' + 'A'
+		}
+	}
+
+	special(3, mode&fs.ModeSetuid != 0, 's')
+	special(6, mode&fs.ModeSetgid != 0, 's')
+	special(9, mode&fs.ModeSticky != 0, 't')
+
+	return string(ret[:])
+}
+
+var userNameCache = make(map[uint32]string)
+var groupNameCache = make(map[uint32]string)
+
+// Falls back to the numeric id if there is no user with this id
+func UserName(uid uint32) string {
+	if name, ok := userNameCache[uid]; ok {
+		return name
+	}
+
+	name := strconv.FormatUint(uint64(uid), 10)
+	u, err := user.LookupId(name)
+	if err == nil {
+		name = u.Username
+	}
+
+	userNameCache[uid] = name
+	return name
+}
+
+// Falls back to the numeric id if there is no group with this id
+func GroupName(gid uint32) string {
+	if name, ok := groupNameCache[gid]; ok {
+		return name
+	}
+
+	name := strconv.FormatUint(uint64(gid), 10)
+	g, err := user.LookupGroupId(name)
+	if err == nil {
+		name = g.Name
+	}
+
+	groupNameCache[gid] = name
+	return name
+}
+
+func FormatSize(size uint64, humanReadable bool) string {
+	if humanReadable {
+		return BytesToHumanReadableUnitString(size, 1)
+	}
+
+	return strconv.FormatUint(size, 10)
+}
+
+// Like GNU ls, files older than 6 months (or in the future) show the year instead of the time of day
+func FormatModifiedTime(t time.Time, now time.Time) string {
+	sixMonthsAgo := now.AddDate(0, -6, 0)
+	if t.Before(sixMonthsAgo) || t.After(now) {
+		return t.Format("Jan _2  2006")
+	}
+
+	return t.Format("Jan _2 15:04")
+}
+
+// The columns shown before the name in --long mode
+type LongColumns struct {
+	mode  string
+	links string
+	owner string
+	group string
+	size  string
+	major string // Instead of the size for block and character devices
+	minor string
+	time  string // The timestamp chosen with --time
+}
+
+func GetLongColumns(stat os.FileInfo, path string, options Options, now time.Time) LongColumns {
+	var ret LongColumns
+	ret.mode = ModeString(stat.Mode()) + XattrSuffix(path)
+
+	sysStat, ok := GetSysStat(stat)
+	if ok {
+		ret.links = strconv.FormatUint(sysStat.nlink, 10)
+		ret.owner = UserName(sysStat.uid)
+		ret.group = GroupName(sysStat.gid)
+	} else if archived, ok := stat.(*archiveFile); ok && archived.owner != "" {
+		// Only tar archives store the owner
+		ret.links = "1"
+		ret.owner = archived.owner
+		ret.group = archived.group
+	} else {
+		ret.links = "?"
+		ret.owner = "?"
+		ret.group = "?"
+	}
+
+	// Devices show their major and minor numbers like GNU ls, e.g. "1, 3" for /dev/null
+	if ok && stat.Mode()&fs.ModeDevice != 0 {
+		ret.major = strconv.FormatUint(uint64(deviceMajor(sysStat.rdev)), 10)
+		ret.minor = strconv.FormatUint(uint64(deviceMinor(sysStat.rdev)), 10)
+	} else {
+		ret.size = FormatSize(uint64(stat.Size()), options.humanReadable)
+	}
+
+	t, ok := EntryTime(stat, path, options.timeField)
+	if ok {
+		ret.time = FormatTime(t, options.timeStyle, now)
+	} else {
+		ret.time = "?"
+	}
+	return ret
+}
+
+// Widest value of each column, used for alignment
+type LongColumnWidths struct {
+	mode  int // 11 if any entry has a '+' or '@' suffix
+	links int
+	owner int
+	group int
+	size  int
+	major int
+	minor int
+	time  int
+}
+
+func (w *LongColumnWidths) Update(c LongColumns) {
+	w.mode = max(w.mode, len(c.mode))
+	w.links = max(w.links, len(c.links))
+	w.owner = max(w.owner, len(c.owner))
+	w.group = max(w.group, len(c.group))
+	w.size = max(w.size, len(c.size))
+	w.major = max(w.major, len(c.major))
+	w.minor = max(w.minor, len(c.minor))
+	w.time = max(w.time, VisibleLength(c.time))
+}
+
+func padLeft(str string, width int) string {
+	return strings.Repeat(" ", max(0, width-len(str))) + str
+}
+
+func padRight(str string, width int) string {
+	return str + strings.Repeat(" ", max(0, width-len(str)))
+}
+
+// Numbers are right-aligned, text is left-aligned. Includes a trailing space before the name column
+func (c LongColumns) Format(w LongColumnWidths) string {
+	size := c.size
+	sizeWidth := w.size
+	if w.major > 0 {
+		sizeWidth = max(sizeWidth, w.major+len(", ")+w.minor)
+	}
+	if c.major != "" {
+		size = padLeft(c.major, w.major) + ", " + padLeft(c.minor, w.minor)
+	}
+
+	return padRight(c.mode, w.mode) + " " +
+		padLeft(c.links, w.links) + " " +
+		padRight(c.owner, w.owner) + " " +
+		padRight(c.group, w.group) + " " +
+		padLeft(size, sizeWidth) + " " +
+		padRight(c.time, w.time) + " "
+}
+
+// Returns the target of the symlink at path as written in the symlink, and the path to the target.
+// Relative targets are resolved relative to the directory containing the symlink
+func SymlinkTarget(path string) (target string, targetPath string, err error) {
+	target, err = os.Readlink(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	targetPath = target
+	if !filepath.IsAbs(targetPath) {
+		targetPath = filepath.Join(filepath.Dir(path), targetPath)
+	}
+
+	return target, targetPath, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModeString(t *testing.T) {
+	type TestCase struct {
+		mode     fs.FileMode
+		expected string
+	}
+
+	tests := []TestCase{
+		{0o644, "-rw-r--r--"},
+		{0o755 | fs.ModeDir, "drwxr-xr-x"},
+		{0o777 | fs.ModeSymlink, "lrwxrwxrwx"},
+		{0o600 | fs.ModeNamedPipe, "prw-------"},
+		{0o755 | fs.ModeSocket, "srwxr-xr-x"},
+		{0o666 | fs.ModeDevice | fs.ModeCharDevice, "crw-rw-rw-"},
+		{0o660 | fs.ModeDevice, "brw-rw----"},
+		{0o4755, "-rwxr-xr-x"}, // Unix permission bits above 0o777 aren't the special bits of fs.FileMode
+		{0o755 | fs.ModeSetuid, "-rwsr-xr-x"},
+		{0o644 | fs.ModeSetuid, "-rwSr--r--"},
+		{0o755 | fs.ModeSetgid, "-rwxr-sr-x"},
+		{0o744 | fs.ModeSetgid, "-rwxr-Sr--"},
+		{0o777 | fs.ModeDir | fs.ModeSticky, "drwxrwxrwt"},
+		{0o770 | fs.ModeDir | fs.ModeSticky, "drwxrwx--T"},
+		{0, "----------"},
+	}
+	for _, test := range tests {
+		result := ModeString(test.mode)
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", uint32(test.mode), "but got", result)
+		}
+	}
+}
+
+func TestLongListing(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no owners or symlinks without extra privileges")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "small"), []byte("small"), 0o644)
+	os.WriteFile(filepath.Join(dir, "large"), make([]byte, 12345), 0o644)
+	os.Chmod(filepath.Join(dir, "small"), 0o644)
+	os.Chmod(filepath.Join(dir, "large"), 0o755)
+	os.Symlink("small", filepath.Join(dir, "link"))
+
+	modTime := time.Date(2020, time.January, 2, 3, 4, 0, 0, time.Local)
+	for _, name := range []string{"small", "large"} {
+		os.Chtimes(filepath.Join(dir, name), modTime, modTime)
+	}
+
+	options := testOptions()
+	options.long = true
+	options.timeStyle = "long-iso"
+	stdout, _, _ := captureOutput(t, func() {
+		entries, trees := CollectEntries([]string{dir}, false, false, options)
+		PrintListing(OrderEntries(entries, options), trees, options)
+	})
+
+	// Symlinks can't portably have their own time set
+	linkStat, _ := os.Lstat(filepath.Join(dir, "link"))
+	linkTime := linkStat.ModTime().Format("2006-01-02 15:04")
+
+	owner := UserName(uint32(os.Getuid())) + " " + GroupName(uint32(os.Getgid()))
+	expected := "-rwxr-xr-x 1 " + owner + " 12345 2020-01-02 03:04 large\n" +
+		"lrwxrwxrwx 1 " + owner + "     5 " + linkTime + " link -> small\n" +
+		"-rw-r--r-- 1 " + owner + "     5 2020-01-02 03:04 small\n"
+	if stdout != expected {
+		t.Fatal("Expected", strings.ReplaceAll(expected, "\n", "|"), "but got", strings.ReplaceAll(stdout, "\n", "|"))
+	}
+}
+
+func TestDeviceNumbers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("device numbers differ between platforms")
+	}
+
+	stat, err := os.Lstat("/dev/null")
+	if err != nil {
+		t.Skip("no /dev/null")
+	}
+
+	columns := GetLongColumns(stat, "/dev/null", testOptions(), time.Now())
+	if columns.major != "1" || columns.minor != "3" || columns.size != "" {
+		t.Fatal("Expected the device numbers 1, 3 instead of a size, but got", columns.major, columns.minor, columns.size)
+	}
+}
+
+func TestLongColumnsFormat(t *testing.T) {
+	file := LongColumns{mode: "-rw-r--r--", links: "1", owner: "alice", group: "staff", size: "123456", time: "Jan  2 03:04"}
+	device := LongColumns{mode: "crw-rw-rw-", links: "1", owner: "root", group: "root", major: "1", minor: "3", time: "Jan  2 03:04"}
+	otherDevice := LongColumns{mode: "brw-rw----", links: "12", owner: "root", group: "disk", major: "259", minor: "10", time: "Jan  2 03:04"}
+
+	type TestCase struct {
+		columns  []LongColumns
+		expected []string
+	}
+
+	tests := []TestCase{
+		{[]LongColumns{file}, []string{"-rw-r--r-- 1 alice staff 123456 Jan  2 03:04 "}},
+		// The major and minor numbers are aligned separately, like GNU ls
+		{[]LongColumns{device, otherDevice}, []string{
+			"crw-rw-rw-  1 root root   1,  3 Jan  2 03:04 ",
+			"brw-rw---- 12 root disk 259, 10 Jan  2 03:04 ",
+		}},
+		// Sizes are right-aligned with the device numbers
+		{[]LongColumns{file, device}, []string{
+			"-rw-r--r-- 1 alice staff 123456 Jan  2 03:04 ",
+			"crw-rw-rw- 1 root  root    1, 3 Jan  2 03:04 ",
+		}},
+	}
+	for _, test := range tests {
+		var widths LongColumnWidths
+		for _, c := range test.columns {
+			widths.Update(c)
+		}
+
+		var result []string
+		for _, c := range test.columns {
+			result = append(result, c.Format(widths))
+		}
+
+		if strings.Join(result, "\n") != strings.Join(test.expected, "\n") {
+			t.Fatal("Expected", test.expected, "but got", result)
+		}
+	}
+}
@@ -3,72 +3,120 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kivattt/getopt"
 	"github.com/kivattt/gogitstatus"
 	"golang.org/x/term"
 )
 
-var validSortByValues = [...]string{
-	"none",
-	"modified",
-}
-
-func SortEntries(entries *[]fs.DirEntry, sortBy string) {
-	switch sortBy {
-	case "modified":
-		slices.SortStableFunc(*entries, func(a, b fs.DirEntry) int {
-			aInfo, aErr := a.Info()
-			bInfo, bErr := b.Info()
-			if aErr != nil || bErr != nil {
-				return 0
-			}
-
-			if aInfo.ModTime().Before(bInfo.ModTime()) {
-				return -1
-			}
-
-			if aInfo.ModTime().Equal(bInfo.ModTime()) {
-				return 0
-			}
-
-			return 1
-		})
-	case "none":
-	default:
-		fmt.Fprintln(os.Stderr, "Invalid sortBy value \""+sortBy+"\"")
-		fmt.Fprintln(os.Stderr, "Valid values: "+strings.Join(validSortByValues[:], ", "))
-		os.Exit(1)
-	}
-}
-
 func printError(msg string, colorEnabled bool) {
 	if colorEnabled {
-		os.Stderr.WriteString("\x1b[1;31m") // Red
+		io.WriteString(errorOutput, "\x1b[1;31m") // Red
 	}
-	os.Stderr.WriteString(msg + "\n")
+	io.WriteString(errorOutput, msg+"\n")
 	if colorEnabled {
-		os.Stderr.WriteString("\x1b[0m") // Reset
+		io.WriteString(errorOutput, "\x1b[0m") // Reset
 	}
 }
 
+// Settings from the command-line flags, shared by every listing
+type Options struct {
+	all               bool
+	directoriesFirst  bool
+	sortBy            string
+	reverse           bool
+	ignoreCase        bool
+	groupBy           string
+	colorEnabled      bool
+	hyperlink         bool
+	icons             bool
+	quotingStyle      string
+	lineTerminator    string // "\x00" with --zero
+	gitStatus         bool
+	gitStatusDetailed bool
+	gitStatusIgnored  bool
+	gitLog            bool
+	xattrs            bool
+	xattrValues       bool
+	context           bool
+	long              bool
+	humanReadable     bool
+	timeField         string // "mtime", "atime", "ctime" or "birth"
+	timeStyle         string
+	useGrid           bool
+	gridWidth         int
+	across            bool
+	format            string
+	sniff             bool
+	gitIgnore         bool
+	ignorePatterns    []string
+	hidePatterns      []string
+	onlyPatterns      []string
+	dirSizes          bool
+	inode             bool
+	links             bool
+	groupHardlinks    bool
+	allocated         bool
+	errorFormat       string // "text" or "json", see reportError()
+	intoArchives      bool
+}
+
 func main() {
 	help := flag.Bool("help", false, "display this help and exit")
 	all := flag.Bool("all", false, "show hidden files starting with '.'")
 	directoriesFirst := flag.Bool("directories-first", false, "show directories first")
 	directory := flag.Bool("directory", false, "list directories themselves, not their contents")
 	sortBy := flag.String("sort-by", "none", "sort files ("+strings.Join(validSortByValues[:], ", ")+")")
-	summary := flag.Bool("summary", false, "folder stats")
+	reverse := flag.Bool("reverse", false, "reverse the sort order")
+	groupBy := flag.String("group-by", "none", "list the entries in sections with headers ("+strings.Join(validGroupByValues[:], ", ")+"), git groups them by --sort-by=git")
+	ignoreCase := flag.Bool("ignore-case", false, "ignore upper/lowercase when sorting by name, extension or version")
+	summary := flag.Bool("summary", false, "folder stats, file sizes and types, and changed files with --git-status")
 	color := flag.String("color", "auto", "colorize the output [auto, always, never]")
-	demo := flag.Bool("demo", false, "show all the file colors")
-	gitStatus := flag.Bool("git-status", false, "highlight changed/untracked files from current working directory")
-	gitStatusDetailed := flag.Bool("git-status-detailed", false, "show more info about changed/untracked files")
+	hyperlink := flag.String("hyperlink", "never", "make the names clickable links to the files in supported terminals [auto, always, never]")
+	iconsFlag := flag.String("icons", "never", "show a Nerd Font icon for the type of each file [auto, always, never]")
+	quotingStyle := flag.String("quoting-style", "auto", "how to quote unusual file names ("+strings.Join(validQuotingStyleValues[:], ", ")+"), auto uses shell-escape in a terminal and literal otherwise")
+	zero := flag.Bool("zero", false, "end each entry with a NUL byte instead of a newline, for xargs -0")
+	demo := flag.Bool("demo", false, "show all the file colors, including those from LS_COLORS and ~/.config/tutils2/ls-colors")
+	gitStatus := flag.Bool("git-status", false, "highlight changed/untracked files in git repositories")
+	gitStatusDetailed := flag.Bool("git-status-detailed", false, "show staged/unstaged/untracked files and what changed, and the number of changed files in directories")
+	gitStatusIgnored := flag.Bool("git-status-ignored", false, "also show ignored files with --git-status-detailed")
+	xattrs := flag.Bool("xattrs", false, "show the names of the extended attributes of each file")
+	xattrValues := flag.Bool("xattr-values", false, "show the values of the extended attributes too, implies --xattrs")
+	context := flag.Bool("context", false, "show the SELinux security context of each file")
+	gitLog := flag.Bool("git-log", false, "show the hash, author and date of the last commit that changed each entry")
+	long := flag.Bool("long", false, "show permissions, link count, owner, group, size and modification time")
+	humanReadable := flag.Bool("human-readable", false, "show sizes like 1.2 MB in --long mode")
+	timeField := flag.String("time", "mtime", "which timestamp to show in --long mode and sort by with --sort-by=time ("+strings.Join(validTimeValues[:], ", ")+")")
+	timeStyle := flag.String("time-style", "default", "how to show timestamps in --long mode ("+strings.Join(validTimeStyleValues[:], ", ")+"), or a date format like +%Y-%m-%d")
+	grid := flag.String("grid", "auto", "show entries in columns sized to the terminal width [auto, always, never]")
+	across := flag.Bool("across", false, "fill the columns row by row instead of column by column")
+	recursive := flag.Bool("recursive", false, "list subdirectories recursively")
+	sniff := flag.Bool("sniff", false, "recognize file types by their contents when the extension is unknown")
+	treeView := flag.Bool("tree", false, "show directories as a tree")
+	depth := flag.Int("depth", -1, "how many levels of directories --tree shows, -1 for no limit")
+	follow := flag.Bool("follow", false, "descend into symlinked directories in --tree")
+	dirSizes := flag.Bool("dir-sizes", false, "show the total size of everything inside each directory, --sort-by=size uses it")
+	allocated := flag.Bool("allocated", false, "with --dir-sizes, show the space allocated on disk instead of the apparent size")
+	gitIgnore := flag.Bool("git-ignore", false, "hide files ignored by git")
+	var ignorePatterns, hidePatterns, onlyPatterns stringList
+	flag.Var(&ignorePatterns, "ignore", "don't list entries matching the glob pattern, can be given multiple times")
+	flag.Var(&hidePatterns, "hide", "like --ignore, unless --all is used")
+	flag.Var(&onlyPatterns, "only", "only list files (not directories) matching the glob pattern, can be given multiple times")
+	format := flag.String("format", "text", "output format ("+strings.Join(validFormatValues[:], ", ")+")")
+	inode := flag.Bool("inode", false, "show the inode number of each file")
+	links := flag.Bool("links", false, "show the number of hard links to each file, --long already shows it")
+	groupHardlinks := flag.Bool("group-hardlinks", false, "tag files that are hard links to the same file with the same [link N]")
+	intoArchives := flag.Bool("into-archives", false, "list the contents of zip, jar and tar(.gz/.bz2) archives like directories, e.g. archive.tar.gz/sub/dir")
+	errorFormat := flag.String("errors", "text", "how to report files that can't be accessed ("+strings.Join(validErrorsValues[:], ", ")+"), json writes one object per line to stderr")
+	watch := flag.Bool("watch", false, "redraw the listing whenever something in the listed directories changes, until Ctrl-C")
 
 	getopt.CommandLine.SetOutput(os.Stdout)
 	getopt.CommandLine.Init("ls", flag.ExitOnError)
@@ -76,11 +124,16 @@ func main() {
 		"h", "help",
 		"a", "all",
 		"d", "directory",
+		"i", "inode",
+		"l", "long",
+		"R", "recursive",
+		"r", "reverse",
+		"0", "zero",
 	)
 
 	err := getopt.CommandLine.Parse(os.Args[1:])
 	if err != nil {
-		os.Exit(0)
+		os.Exit(exitSeriousProblem)
 	}
 
 	if *help {
@@ -91,6 +144,62 @@ func main() {
 		os.Exit(0)
 	}
 
+	if !slices.Contains(validSortByValues[:], *sortBy) {
+		fmt.Fprintln(os.Stderr, "Invalid sortBy value \""+*sortBy+"\"")
+		fmt.Fprintln(os.Stderr, "Valid values: "+strings.Join(validSortByValues[:], ", "))
+		os.Exit(exitSeriousProblem)
+	}
+
+	if !slices.Contains(validGroupByValues[:], *groupBy) {
+		fmt.Fprintln(os.Stderr, "Invalid group-by value \""+*groupBy+"\"")
+		fmt.Fprintln(os.Stderr, "Valid values: "+strings.Join(validGroupByValues[:], ", "))
+		os.Exit(exitSeriousProblem)
+	}
+
+	if !slices.Contains(validTimeValues[:], *timeField) {
+		fmt.Fprintln(os.Stderr, "Invalid time value \""+*timeField+"\"")
+		fmt.Fprintln(os.Stderr, "Valid values: "+strings.Join(validTimeValues[:], ", "))
+		os.Exit(exitSeriousProblem)
+	}
+
+	if !slices.Contains(validTimeStyleValues[:], *timeStyle) && !strings.HasPrefix(*timeStyle, "+") {
+		fmt.Fprintln(os.Stderr, "Invalid time-style value \""+*timeStyle+"\"")
+		fmt.Fprintln(os.Stderr, "Valid values: "+strings.Join(validTimeStyleValues[:], ", ")+", or +FORMAT")
+		os.Exit(exitSeriousProblem)
+	}
+
+	if !slices.Contains(validFormatValues[:], *format) {
+		fmt.Fprintln(os.Stderr, "Invalid format value \""+*format+"\"")
+		fmt.Fprintln(os.Stderr, "Valid values: "+strings.Join(validFormatValues[:], ", "))
+		os.Exit(exitSeriousProblem)
+	}
+
+	if !slices.Contains(validErrorsValues[:], *errorFormat) {
+		fmt.Fprintln(os.Stderr, "Invalid errors value \""+*errorFormat+"\"")
+		fmt.Fprintln(os.Stderr, "Valid values: "+strings.Join(validErrorsValues[:], ", "))
+		os.Exit(exitSeriousProblem)
+	}
+
+	for _, pattern := range slices.Concat(ignorePatterns, hidePatterns, onlyPatterns) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid glob pattern \""+pattern+"\"")
+			os.Exit(exitSeriousProblem)
+		}
+	}
+
+	quotingStyleToUse := *quotingStyle
+	if quotingStyleToUse == "auto" {
+		if *zero || !term.IsTerminal(int(os.Stdout.Fd())) {
+			quotingStyleToUse = "literal" // Output is piped, leave the names as they are
+		} else {
+			quotingStyleToUse = "shell-escape" // Don't let file names mess with the terminal
+		}
+	} else if !slices.Contains(validQuotingStyleValues[:], quotingStyleToUse) {
+		fmt.Fprintln(os.Stderr, "Invalid quoting-style value \""+quotingStyleToUse+"\"")
+		fmt.Fprintln(os.Stderr, "Valid values: auto, "+strings.Join(validQuotingStyleValues[:], ", "))
+		os.Exit(exitSeriousProblem)
+	}
+
 	colorToUse := *color
 	if colorToUse == "auto" {
 		if !term.IsTerminal(int(os.Stdout.Fd())) {
@@ -98,15 +207,34 @@ func main() {
 		}
 	}
 
+	hyperlinkToUse := *hyperlink
+	if hyperlinkToUse == "auto" {
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			hyperlinkToUse = "never" // Output is piped, don't add hyperlinks
+		}
+	}
+
+	iconsToUse := *iconsFlag
+	if iconsToUse == "auto" {
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			iconsToUse = "never" // Output is piped, the icons would get in the way
+		}
+	}
+
+	gridToUse := *grid
+	if gridToUse == "auto" {
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			gridToUse = "never" // Output is piped, one entry per line
+		}
+	}
+
+	for _, err := range LoadColors() {
+		printError(err.Error(), colorToUse != "never")
+	}
+
 	if *demo {
-		for key, val := range colors {
-			if colorToUse != "never" {
-				fmt.Print(val)
-			}
-			fmt.Println(key)
-			if colorToUse != "never" {
-				fmt.Print("\x1b[0m") // Reset color
-			}
+		for _, line := range DemoLines(colorToUse != "never") {
+			fmt.Println(line)
 		}
 
 		os.Exit(0)
@@ -117,7 +245,7 @@ func main() {
 		cwd = os.Getenv("PWD")
 		if cwd == "" {
 			printError("PWD environment variable empty, unable to determine current working directory", colorToUse != "never")
-			os.Exit(1)
+			os.Exit(exitSeriousProblem)
 		}
 	}
 
@@ -128,153 +256,608 @@ func main() {
 		paths = getopt.CommandLine.Args()
 	}
 
-	var allEntries []fs.DirEntry
-	longestEntryBasename := 0
+	var options Options
+	options.all = *all
+	options.directoriesFirst = *directoriesFirst
+	options.sortBy = *sortBy
+	options.reverse = *reverse
+	options.ignoreCase = *ignoreCase
+	options.groupBy = *groupBy
+	options.colorEnabled = colorToUse != "never"
+	options.hyperlink = hyperlinkToUse != "never"
+	options.icons = iconsToUse != "never"
+	options.quotingStyle = quotingStyleToUse
+	hideControlChars = !*zero && term.IsTerminal(int(os.Stdout.Fd()))
+	options.lineTerminator = "\n"
+	if *zero {
+		options.lineTerminator = "\x00"
+	}
+	options.gitStatus = *gitStatus || *gitStatusDetailed
+	options.gitStatusDetailed = *gitStatusDetailed
+	options.gitStatusIgnored = *gitStatusIgnored
+	options.gitLog = *gitLog
+	options.xattrs = *xattrs || *xattrValues
+	options.xattrValues = *xattrValues
+	options.context = *context
+	options.long = *long
+	options.humanReadable = *humanReadable
+	options.timeField = *timeField
+	options.timeStyle = *timeStyle
+	options.across = *across
+	options.format = *format
+	options.sniff = *sniff
+	options.gitIgnore = *gitIgnore
+	options.dirSizes = *dirSizes
+	options.inode = *inode
+	options.links = *links
+	options.groupHardlinks = *groupHardlinks
+	options.allocated = *allocated
+	options.errorFormat = *errorFormat
+	options.intoArchives = *intoArchives
+	options.ignorePatterns = ignorePatterns
+	options.hidePatterns = hidePatterns
+	options.onlyPatterns = onlyPatterns
+
+	// Columns don't make sense when each entry has extra info on its line
+	options.useGrid = gridToUse != "never" && !*long && !*gitStatusDetailed && !*gitLog && !options.xattrs && !*context && !*groupHardlinks && !*dirSizes && !*zero
+	gridWidth, ok := TerminalWidth()
+	if !ok {
+		gridWidth = 80
+	}
+	options.gridWidth = gridWidth
+
+	if *watch {
+		if options.format != "text" || *summary || *treeView {
+			fmt.Fprintln(os.Stderr, "--watch can only be used with --format=text, and not with --summary or --tree")
+			os.Exit(exitSeriousProblem)
+		}
+
+		Watch(paths, func() ([]Entry, []DirectoryTree) {
+			return CollectEntries(paths, *directory, *recursive, options)
+		}, options)
+		os.Exit(exitStatus)
+	}
+
+	if *treeView {
+		// The tree only shows names, with the colors, icons, sorting and filtering of a listing
+		var unsupported []string
+		if options.format != "text" {
+			unsupported = append(unsupported, "--format="+options.format)
+		}
+		flags := []struct {
+			name string
+			set  bool
+		}{
+			{"--summary", *summary},
+			{"--long", *long},
+			{"--recursive", *recursive},
+			{"--directory", *directory},
+			{"--git-status-detailed", *gitStatusDetailed},
+			{"--git-log", *gitLog},
+			{"--inode", *inode},
+			{"--links", *links},
+			{"--xattrs", options.xattrs},
+			{"--context", *context},
+			{"--group-hardlinks", *groupHardlinks},
+			{"--group-by=" + *groupBy, *groupBy != "none"},
+			{"--into-archives", *intoArchives},
+		}
+		for _, flag := range flags {
+			if flag.set {
+				unsupported = append(unsupported, flag.name)
+			}
+		}
+
+		if len(unsupported) > 0 {
+			fmt.Fprintln(os.Stderr, "--tree can't be used with "+strings.Join(unsupported, ", "))
+			os.Exit(exitSeriousProblem)
+		}
+
+		for _, path := range paths {
+			PrintTree(path, *depth, *follow, options)
+		}
+		os.Exit(exitStatus)
+	}
+
+	allEntries, trees := CollectEntries(paths, *directory, *recursive, options)
+
+	// Just folder stats, don't need any sorting
+	if *summary {
+		for _, tree := range trees {
+			allEntries = append(allEntries, tree.AllEntries()...)
+		}
+
+		if options.sniff {
+			SniffEntries(allEntries)
+		}
+
+		var changedOrUntracked map[string]gogitstatus.ChangedFile
+		if options.gitStatus {
+			changedOrUntracked = GitStatusOfEntries(allEntries)
+		}
+
+		PrintSummary(GetSummary(allEntries, changedOrUntracked), options)
+		os.Exit(exitStatus)
+	}
+
+	PrintListing(allEntries, trees, options)
+
+	if *format == "json" {
+		FlushJSON()
+	}
+
+	os.Exit(exitStatus)
+}
+
+// Returns the filtered entries of the paths, and the trees of the directories with --recursive.
+// Files given as arguments are entries themselves, as are directories with --directory
+func CollectEntries(paths []string, directory bool, recursive bool, options Options) ([]Entry, []DirectoryTree) {
+	var allEntries []Entry
+	var trees []DirectoryTree
 
 	for _, path := range paths {
+		// An archive is listed like a directory, unless it is given as-is with --directory
+		if options.intoArchives {
+			if archivePath, inner, ok := SplitArchivePath(path); ok && (inner != "" || !directory) {
+				entries, archiveTrees := CollectArchiveEntries(path, archivePath, inner, directory, recursive, options)
+				allEntries = append(allEntries, entries...)
+				trees = append(trees, archiveTrees...)
+				continue
+			}
+		}
+
 		stat, err := os.Lstat(path)
 		if err != nil {
-			printError("Failed to stat: '"+path+"'", colorToUse != "never")
+			reportError("Failed to stat", path, err, exitSeriousProblem, options)
+			continue
+		}
+
+		if !stat.IsDir() || directory {
+			allEntries = append(allEntries, Entry{fs.FileInfoToDirEntry(stat), path})
 			continue
 		}
 
-		if !stat.IsDir() || *directory {
-			longestEntryBasename = max(longestEntryBasename, len(stat.Name()))
-			allEntries = append(allEntries, fs.FileInfoToDirEntry(stat))
+		if recursive {
+			trees = append(trees, WalkDirectoryTree(path, options))
 			continue
 		}
 
+		// Whatever could be read before an error is still listed
 		entries, err := os.ReadDir(path)
 		if err != nil {
-			printError("Failed to read directory '"+path+"'", colorToUse != "never")
-			continue
+			reportError("Failed to read directory", path, err, exitSeriousProblem, options)
 		}
 
 		for _, entry := range entries {
-			longestEntryBasename = max(longestEntryBasename, len(entry.Name()))
+			allEntries = append(allEntries, Entry{entry, filepath.Join(path, entry.Name())})
 		}
-		allEntries = append(allEntries, entries...)
 	}
 
-	// Just folder stats, don't need any sorting
-	if *summary {
-		folderCount := 0
-		fileCount := 0
-		hiddenFolderCount := 0
-		hiddenFileCount := 0
-
-		for _, e := range allEntries {
-			isHidden := strings.HasPrefix(e.Name(), ".")
-
-			if e.IsDir() {
-				if isHidden {
-					hiddenFolderCount++
-				} else {
-					folderCount++
+	return FilterEntries(allEntries, options), trees
+}
+
+// Prints the entries, then each tree
+func PrintListing(allEntries []Entry, trees []DirectoryTree, options Options) {
+	listed := slices.Clone(allEntries)
+	for _, tree := range trees {
+		listed = append(listed, tree.AllEntries()...)
+	}
+
+	// The history is walked once for everything listed
+	if options.gitLog && options.format == "text" {
+		LoadLastCommits(listed)
+	}
+
+	// Hard links are grouped across every directory listed
+	if options.groupHardlinks && options.format == "text" {
+		LoadHardlinkGroups(listed)
+	}
+
+	// Like GNU ls, files given as arguments are listed before the directories
+	if len(allEntries) > 0 || len(trees) == 0 {
+		PrintGroupedEntries(OrderEntries(allEntries, options), options)
+	}
+
+	for i, tree := range trees {
+		PrintDirectoryTree(tree, options, i > 0 || len(allEntries) > 0)
+	}
+}
+
+// Returns the entries in the order they should be listed
+func OrderEntries(entries []Entry, options Options) []Entry {
+	if options.dirSizes {
+		var visible []Entry
+		for _, e := range entries {
+			if options.all || !strings.HasPrefix(e.Name(), ".") {
+				visible = append(visible, e)
+			}
+		}
+		ComputeEntrySizes(visible, options.allocated)
+	}
+
+	SortEntries(&entries, options.sortBy, options.timeField, options.ignoreCase)
+
+	if options.reverse {
+		slices.Reverse(entries)
+	}
+
+	if options.directoriesFirst {
+		entries = FoldersAtBeginning(entries)
+	}
+
+	return entries
+}
+
+// Prints the entries in sections with a header for each group of --group-by, keeping their order within each group.
+// Without --group-by it is the same as PrintEntries()
+func PrintGroupedEntries(entries []Entry, options Options) {
+	if options.groupBy != "git" || options.format != "text" {
+		PrintEntries(entries, options)
+		return
+	}
+
+	detailedStatus := GitDetailedStatusOfEntries(entries, false)
+	groups := make([][]Entry, len(gitGroupNames))
+	for _, e := range entries {
+		group := gitGroupOf(detailedStatus, e.path)
+		groups[group] = append(groups[group], e)
+	}
+
+	headerColors := [len(gitGroupNames)]string{gitStateColors["untracked"], gitStateColors["unstaged"], ""}
+
+	printSeparator := false
+	for i, group := range groups {
+		// Groups with only hidden entries are left out entirely
+		visible := slices.ContainsFunc(group, func(e Entry) bool {
+			return options.all || !strings.HasPrefix(e.Name(), ".")
+		})
+		if !visible {
+			continue
+		}
+
+		if printSeparator {
+			fmt.Print(options.lineTerminator)
+		}
+		printSeparator = true
+
+		if options.colorEnabled && headerColors[i] != "" {
+			fmt.Print(headerColors[i] + gitGroupNames[i] + ":\x1b[0m" + options.lineTerminator)
+		} else {
+			fmt.Print(gitGroupNames[i] + ":" + options.lineTerminator)
+		}
+
+		PrintEntries(group, options)
+	}
+}
+
+// Prints the entries of a single listing, in the order given
+func PrintEntries(entries []Entry, options Options) {
+	var changedOrUntracked map[string]gogitstatus.ChangedFile
+	if options.gitStatus {
+		changedOrUntracked = GitStatusOfEntries(entries)
+	}
+
+	var detailedStatus map[string]GitDetailedStatus
+	if options.gitStatusDetailed && options.format == "text" {
+		detailedStatus = GitDetailedStatusOfEntries(entries, options.gitStatusIgnored)
+	}
+
+	if options.sniff {
+		var visible []Entry
+		for _, e := range entries {
+			if options.all || !strings.HasPrefix(e.Name(), ".") {
+				visible = append(visible, e)
+			}
+		}
+		SniffEntries(visible)
+	}
+
+	if options.format != "text" {
+		PrintEntriesJSON(entries, changedOrUntracked, options)
+		return
+	}
+
+	longestEntryBasename := 0
+	for _, e := range entries {
+		if !options.all && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		longestEntryBasename = max(longestEntryBasename, VisibleLength(QuoteName(e.Name(), options.quotingStyle)))
+	}
+
+	// Computed up-front so the columns can be aligned
+	var longColumns []LongColumns
+	var longWidths LongColumnWidths
+	var symlinkTargets []string
+	var symlinkTargetPaths []string
+	if options.long {
+		now := time.Now()
+		longColumns = make([]LongColumns, len(entries))
+		symlinkTargets = make([]string, len(entries))
+		symlinkTargetPaths = make([]string, len(entries))
+
+		for i, e := range entries {
+			if !options.all && strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+
+			longColumns[i] = GetLongColumns(info, e.path, options, now)
+			longWidths.Update(longColumns[i])
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				target, targetPath, err := SymlinkTarget(e.path)
+				if archived, ok := info.(*archiveFile); ok {
+					target, targetPath, err = archived.linkTarget, filepath.Join(filepath.Dir(e.path), archived.linkTarget), nil
 				}
-			} else {
-				if isHidden {
-					hiddenFileCount++
-				} else {
-					fileCount++
+				if err == nil {
+					symlinkTargets[i] = target
+					symlinkTargetPaths[i] = targetPath
+					longestEntryBasename = max(longestEntryBasename, VisibleLength(QuoteName(e.Name(), options.quotingStyle)+" -> "+QuoteName(target, options.quotingStyle)))
 				}
 			}
 		}
+	}
 
-		fmt.Print(folderCount, " folders")
-		if hiddenFolderCount > 0 {
-			fmt.Print(" (" + strconv.Itoa(hiddenFolderCount) + " hidden)")
+	// Tags of --group-hardlinks are shown right after the name
+	hardlinkTags := make([]string, len(entries))
+	for i, e := range entries {
+		group, ok := caches.hardlinkGroups[e.path]
+		if !ok || (!options.all && strings.HasPrefix(e.Name(), ".")) {
+			continue
 		}
-		fmt.Println()
 
-		fmt.Print(fileCount, " files")
-		if hiddenFileCount > 0 {
-			fmt.Print("   (" + strconv.Itoa(hiddenFileCount) + " hidden)")
+		hardlinkTags[i] = " " + FormatHardlinkTag(group, options.colorEnabled)
+
+		nameLength := VisibleLength(QuoteName(e.Name(), options.quotingStyle) + hardlinkTags[i])
+		if options.long && symlinkTargets[i] != "" {
+			nameLength += VisibleLength(" -> " + QuoteName(symlinkTargets[i], options.quotingStyle))
 		}
-		fmt.Println()
+		longestEntryBasename = max(longestEntryBasename, nameLength)
+	}
 
-		fmt.Println(folderCount+hiddenFolderCount+fileCount+hiddenFileCount, "total")
-		os.Exit(0)
+	var sizes []string
+	sizeWidth := 0
+	if options.dirSizes {
+		sizes = make([]string, len(entries))
+		for i, e := range entries {
+			size, ok := caches.entrySizes[e.path]
+			if !ok {
+				continue
+			}
+
+			sizes[i] = FormatSize(size, options.humanReadable)
+			if options.long {
+				longColumns[i].size = sizes[i]
+				longWidths.Update(longColumns[i])
+			}
+			sizeWidth = max(sizeWidth, len(sizes[i]))
+		}
 	}
 
-	SortEntries(&allEntries, *sortBy)
+	// Columns of --git-log, aligned by the longest author
+	var lastCommitColumns []string
+	lastCommitWidth := 0
+	if options.gitLog {
+		now := time.Now()
+		authorWidth := 0
+		for _, e := range entries {
+			if lastCommit, ok := caches.lastCommits[e.path]; ok {
+				authorWidth = max(authorWidth, VisibleLength(lastCommit.author))
+			}
+		}
+
+		lastCommitColumns = make([]string, len(entries))
+		for i, e := range entries {
+			lastCommit, ok := caches.lastCommits[e.path]
+			if !ok {
+				continue
+			}
 
-	if *directoriesFirst {
-		allEntries = FoldersAtBeginning(allEntries)
+			hash := lastCommit.hash[:7]
+			if options.colorEnabled {
+				hash = "\x1b[0;33m" + hash + "\x1b[0m" // Yellow, like git log
+			}
+
+			lastCommitColumns[i] = hash + " " + lastCommit.author + strings.Repeat(" ", authorWidth-VisibleLength(lastCommit.author)) + " " + FormatRelativeTime(lastCommit.date, now)
+			lastCommitWidth = max(lastCommitWidth, VisibleLength(lastCommitColumns[i]))
+		}
 	}
 
-	var changedOrUntracked map[string]gogitstatus.ChangedFile
-	if *gitStatus {
-		changedOrUntracked, _ = gogitstatus.Status(".")
-		changedOrUntracked = gogitstatus.IncludingDirectories(changedOrUntracked)
+	// Columns before everything else, like GNU ls --inode
+	var inodes, linkCounts []string
+	inodeWidth, linkCountWidth := 0, 0
+	if options.inode || (options.links && !options.long) {
+		inodes = make([]string, len(entries))
+		linkCounts = make([]string, len(entries))
+		for i, e := range entries {
+			inodes[i] = "?"
+			linkCounts[i] = "?"
+
+			info, err := e.Info()
+			if err == nil {
+				if sysStat, ok := GetSysStat(info); ok {
+					inodes[i] = strconv.FormatUint(sysStat.inode, 10)
+					linkCounts[i] = strconv.FormatUint(sysStat.nlink, 10)
+				}
+			}
+
+			if options.all || !strings.HasPrefix(e.Name(), ".") {
+				inodeWidth = max(inodeWidth, len(inodes[i]))
+				linkCountWidth = max(linkCountWidth, len(linkCounts[i]))
+			}
+		}
+	}
+
+	var contexts []string
+	contextWidth := 0
+	if options.context {
+		contexts = make([]string, len(entries))
+		for i, e := range entries {
+			contexts[i] = SELinuxContext(e.path)
+			contextWidth = max(contextWidth, VisibleLength(contexts[i]))
+		}
 	}
 
-	for _, e := range allEntries {
-		if !*all && strings.HasPrefix(e.Name(), ".") {
+	var cells []string
+	for i, e := range entries {
+		if !options.all && strings.HasPrefix(e.Name(), ".") {
 			continue
 		}
 
 		info, err := e.Info()
 		if err != nil {
-			printError("Failed to stat: '"+e.Name()+"'", colorToUse != "never")
+			reportError("Failed to stat", e.path, err, exitMinorProblem, options)
 			continue
 		}
 
-		// FIXME: Check correct parent folder first, e.g. with filepath.Rel() ?
-		changedFile, ok := changedOrUntracked[e.Name()]
+		var line strings.Builder
+
+		_, changed := changedOrUntracked[e.path]
+
+		if options.inode {
+			line.WriteString(padLeft(inodes[i], inodeWidth) + " ")
+		}
+
+		if options.links && !options.long {
+			line.WriteString(padLeft(linkCounts[i], linkCountWidth) + " ")
+		}
+
+		if options.long {
+			line.WriteString(longColumns[i].Format(longWidths))
+		}
+
+		line.WriteString(FormatName(e, info, changed, options))
+
+		nameLength := VisibleLength(QuoteName(e.Name(), options.quotingStyle))
+		if options.long && symlinkTargets[i] != "" {
+			line.WriteString(FormatSymlinkTarget(symlinkTargets[i], symlinkTargetPaths[i], options))
+			nameLength += VisibleLength(" -> " + QuoteName(symlinkTargets[i], options.quotingStyle))
+		}
+
+		line.WriteString(hardlinkTags[i])
+		nameLength += VisibleLength(hardlinkTags[i])
 
-		if colorToUse != "never" {
-			if ok {
-				fmt.Print("\x1b[0;31m") // Red background
+		// Columns after the name are aligned after the longest name
+		firstExtraColumn := true
+		beginExtraColumn := func() {
+			if firstExtraColumn {
+				line.WriteString(strings.Repeat(" ", 1+longestEntryBasename-nameLength))
+				firstExtraColumn = false
 			} else {
-				fmt.Print(FileColor(info, e.Name()))
+				line.WriteString(" ")
 			}
 		}
 
-		if ok && *gitStatusDetailed {
-			fmt.Print(e.Name())
-			if colorToUse != "never" {
-				fmt.Print("\x1b[0m")
-			}
+		if options.dirSizes && !options.long {
+			beginExtraColumn()
+			line.WriteString(padLeft(sizes[i], sizeWidth))
+		}
 
-			nSpaces := 1 + longestEntryBasename - len(e.Name())
-			spaces := strings.Repeat(" ", nSpaces)
+		if options.context {
+			beginExtraColumn()
+			line.WriteString(padRight(contexts[i], contextWidth))
+		}
 
-			whatChanged := gogitstatus.WhatChangedToString(changedFile.WhatChanged)
-			nSpaces2nd := 1 + len("OWNER_CHANGED") - len(whatChanged) // "OWNER_CHANGED" is the longest possible
-			spaces2nd := strings.Repeat(" ", nSpaces2nd)
+		var xattrs string
+		if options.xattrs {
+			xattrs = FormatXattrs(e.path, options.xattrValues)
+		}
 
-			if changedFile.Untracked {
-				fmt.Print(spaces + whatChanged + spaces2nd)
-				if colorToUse != "never" {
-					fmt.Print("\x1b[0;31m") // Red
-				}
-				fmt.Print("untracked")
-				if colorToUse != "never" {
-					fmt.Print("\x1b[0m")
-				}
-				fmt.Println()
-			} else {
-				fmt.Print(spaces + whatChanged + spaces2nd)
-				if colorToUse != "never" {
-					fmt.Print("\x1b[38;2;254;229;65m") // Yellow
-				}
-				fmt.Print("unstaged")
-				if colorToUse != "never" {
-					fmt.Print("\x1b[0m")
-				}
-				fmt.Println()
+		// Entries without a commit are padded, so the next column stays aligned
+		_, hasDetailedStatus := detailedStatus[e.path]
+		if lastCommitWidth > 0 && (lastCommitColumns[i] != "" || hasDetailedStatus || xattrs != "") {
+			beginExtraColumn()
+			line.WriteString(lastCommitColumns[i])
+			if hasDetailedStatus || xattrs != "" {
+				line.WriteString(strings.Repeat(" ", lastCommitWidth-VisibleLength(lastCommitColumns[i])))
 			}
+		}
+
+		if status, ok := detailedStatus[e.path]; ok {
+			beginExtraColumn()
+			line.WriteString(FormatGitDetailedStatus(status, e.IsDir(), options))
+		}
+
+		// Last, since it can be long
+		if xattrs != "" {
+			beginExtraColumn()
+			line.WriteString(xattrs)
+		}
+
+		if options.useGrid {
+			cells = append(cells, line.String())
 		} else {
-			fmt.Print(e.Name())
-			if colorToUse != "never" {
-				fmt.Print("\x1b[0m")
-			}
-			fmt.Println()
+			fmt.Print(line.String() + options.lineTerminator)
 		}
+	}
 
-		if colorToUse != "never" {
-			fmt.Print("\x1b[0m")
+	if options.useGrid {
+		for _, gridLine := range GridLines(cells, options.gridWidth, options.across) {
+			fmt.Print(gridLine + options.lineTerminator)
 		}
 	}
 }
+
+// Returns the colored name of the entry, changed is true if it has changed according to git
+func FormatName(e Entry, info os.FileInfo, changed bool, options Options) string {
+	var ret strings.Builder
+
+	if options.colorEnabled {
+		if changed {
+			ret.WriteString("\x1b[0;31m") // Red background
+		} else {
+			ret.WriteString(FileColor(info, e.path))
+		}
+
+		if highlightedEntries[e.path] {
+			ret.WriteString("\x1b[7m") // Inverted
+		}
+	}
+
+	// Every name gets the same width icon, so it doesn't affect the alignment
+	if options.icons {
+		ret.WriteString(FileIcon(info, e.path) + " ")
+	}
+
+	name := QuoteName(e.Name(), options.quotingStyle)
+	if options.hyperlink {
+		ret.WriteString(Hyperlink(name, e.path))
+	} else {
+		ret.WriteString(name)
+	}
+
+	if options.colorEnabled {
+		ret.WriteString("\x1b[0m")
+	}
+
+	return ret.String()
+}
+
+// Returns " -> target", with the target colored like the file it points to
+func FormatSymlinkTarget(target, targetPath string, options Options) string {
+	var ret strings.Builder
+
+	ret.WriteString(" -> ")
+	if options.colorEnabled {
+		targetStat, err := os.Lstat(targetPath)
+		if err != nil && options.intoArchives {
+			targetStat, err = LstatInArchive(targetPath)
+		}
+		if err != nil {
+			ret.WriteString(colors["missing"])
+		} else {
+			ret.WriteString(FileColor(targetStat, targetPath))
+		}
+	}
+	ret.WriteString(QuoteName(target, options.quotingStyle))
+	if options.colorEnabled {
+		ret.WriteString("\x1b[0m")
+	}
+
+	return ret.String()
+}
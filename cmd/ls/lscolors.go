@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A color from LS_COLORS, the theme file or the built-in colors map
+type ColorRule struct {
+	color  string // ANSI escape code, e.g. "\x1b[1;34m"
+	source string // Shown in --demo, e.g. "LS_COLORS"
+}
+
+const builtInSource = "built-in"
+
+// Where each color in the colors map came from
+var colorSources = make(map[string]string)
+
+// Colors for file name suffixes like "*.log" in LS_COLORS, keyed by the lowercase suffix, e.g. ".log"
+var extensionColors = make(map[string]ColorRule)
+
+// Where "ln=target" was set, if set symlinks are colored like the file they point to
+var symlinkColorIsTargetSource = ""
+
+// LS_COLORS keys and the colors map keys they set, see "dircolors --print-database"
+var lsColorsKeys = map[string][]string{
+	"no": {"nothing"},
+	"fi": {"nothing"},
+	"di": {"directory"},
+	"ex": {"executable"},
+	"ln": {"symlink", "symlink_directory"},
+	"or": {"symlink_broken"},
+	"mi": {"missing"},
+	"pi": {"pipe"},
+	"so": {"socket"},
+	"bd": {"block_device"},
+	"cd": {"char_device"},
+	"su": {"setuid"},
+	"sg": {"setgid"},
+	"st": {"sticky"},
+	"ow": {"other_writable"},
+	"tw": {"sticky_other_writable"},
+}
+
+// The built-in file type lists, extendable in the theme file with e.g. "image+=.heic .avif"
+var categoryTypes = map[string]*[]string{
+	"image":    &imageTypes,
+	"video":    &videoTypes,
+	"audio":    &audioTypes,
+	"archive":  &archiveTypes,
+	"code":     &codeTypes,
+	"document": &documentTypes,
+}
+
+// Returns the theme file path, usually ~/.config/tutils2/ls-colors
+func ThemeFilePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "tutils2", "ls-colors"), nil
+}
+
+// Turns SGR parameters like "01;34" into an ANSI escape code
+func sgrToEscapeCode(sgr string) (string, error) {
+	if sgr == "" {
+		return "", nil
+	}
+
+	for _, c := range sgr {
+		if (c < '0' || c > '9') && c != ';' {
+			return "", errors.New("invalid color \"" + sgr + "\", expected numbers separated by ';' like \"01;34\"")
+		}
+	}
+
+	return "\x1b[" + sgr + "m", nil
+}
+
+// Applies a single "key=value" rule, where key is an LS_COLORS key like "di",
+// a suffix glob like "*.log" or a key in the colors map like "image"
+func applyColorRule(key, value, source string) error {
+	if key == "ln" && value == "target" {
+		symlinkColorIsTargetSource = source
+		return nil
+	}
+
+	color, err := sgrToEscapeCode(value)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(key, "*") {
+		suffix := strings.ToLower(key[1:])
+		if suffix == "" {
+			return errors.New("empty suffix in \"" + key + "\"")
+		}
+
+		extensionColors[suffix] = ColorRule{color: color, source: source}
+		return nil
+	}
+
+	if colorsKeys, ok := lsColorsKeys[key]; ok {
+		for _, k := range colorsKeys {
+			colors[k] = color
+			colorSources[k] = source
+		}
+		return nil
+	}
+
+	if _, ok := colors[key]; ok {
+		colors[key] = color
+		colorSources[key] = source
+		return nil
+	}
+
+	// LS_COLORS has keys we don't use, like "rs" or "ca", ignore them
+	return nil
+}
+
+// Parses the LS_COLORS format, e.g. "di=01;34:ln=01;36:*.tar=01;31"
+func ParseLSColors(lsColors string) error {
+	for _, entry := range strings.Split(lsColors, ":") {
+		if entry == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(entry, "=")
+		if !found {
+			return errors.New("missing '=' in LS_COLORS entry \"" + entry + "\"")
+		}
+
+		err := applyColorRule(key, value, "LS_COLORS")
+		if err != nil {
+			return errors.New("LS_COLORS: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Parses the theme file, one rule per line in the same "key=value" syntax as LS_COLORS.
+// Lines like "image+=.heic .avif" add file extensions to the built-in type lists,
+// and lines like "icon.directory=<glyph>" or "icon.*.go=<glyph>" set the --icons.
+// Empty lines and lines starting with '#' are ignored
+func ParseThemeFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		source := path + ":" + strconv.Itoa(lineNumber)
+
+		if category, extensions, found := strings.Cut(line, "+="); found {
+			types, ok := categoryTypes[strings.TrimSpace(category)]
+			if !ok {
+				return errors.New(source + ": unknown file type \"" + strings.TrimSpace(category) + "\"")
+			}
+
+			for _, extension := range strings.Fields(extensions) {
+				*types = append(*types, strings.ToLower(extension))
+			}
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return errors.New(source + ": missing '='")
+		}
+
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if iconKey, isIcon := strings.CutPrefix(key, "icon."); isIcon {
+			err := applyIconRule(iconKey, value)
+			if err != nil {
+				return errors.New(source + ": " + err.Error())
+			}
+			continue
+		}
+
+		err := applyColorRule(key, value, source)
+		if err != nil {
+			return errors.New(source + ": " + err.Error())
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Applies LS_COLORS, then the theme file on top of the built-in colors.
+// Errors are returned, but the valid rules before them still apply
+func LoadColors() []error {
+	for key := range colors {
+		colorSources[key] = builtInSource
+	}
+
+	var errs []error
+
+	lsColors := os.Getenv("LS_COLORS")
+	if lsColors != "" {
+		err := ParseLSColors(lsColors)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	themeFilePath, err := ThemeFilePath()
+	if err == nil {
+		err = ParseThemeFile(themeFilePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Returns the color for the longest matching suffix rule
+func MatchExtensionColor(path string) (ColorRule, bool) {
+	lower := strings.ToLower(path)
+
+	var ret ColorRule
+	longest := -1
+	for suffix, rule := range extensionColors {
+		if len(suffix) > longest && strings.HasSuffix(lower, suffix) {
+			ret = rule
+			longest = len(suffix)
+		}
+	}
+
+	return ret, longest != -1
+}
+
+// Lines for --demo, each showing a color rule in its own color and where it came from
+func DemoLines(colorEnabled bool) []string {
+	type demoLine struct {
+		name   string
+		color  string
+		source string
+	}
+
+	var lines []demoLine
+	for key, color := range colors {
+		lines = append(lines, demoLine{key, color, colorSources[key]})
+	}
+	slices.SortFunc(lines, func(a, b demoLine) int { return strings.Compare(a.name, b.name) })
+
+	var extensionLines []demoLine
+	for suffix, rule := range extensionColors {
+		extensionLines = append(extensionLines, demoLine{"*" + suffix, rule.color, rule.source})
+	}
+	slices.SortFunc(extensionLines, func(a, b demoLine) int { return strings.Compare(a.name, b.name) })
+	lines = append(lines, extensionLines...)
+
+	if symlinkColorIsTargetSource != "" {
+		lines = append(lines, demoLine{"ln=target", "", symlinkColorIsTargetSource})
+	}
+
+	longestName := 0
+	for _, line := range lines {
+		longestName = max(longestName, len(line.name))
+	}
+
+	var ret []string
+	for _, line := range lines {
+		var str strings.Builder
+		if colorEnabled {
+			str.WriteString(line.color)
+		}
+		str.WriteString(line.name)
+		if colorEnabled {
+			str.WriteString("\x1b[0m")
+		}
+		str.WriteString(strings.Repeat(" ", 1+longestName-len(line.name)))
+		str.WriteString(line.source)
+		ret = append(ret, str.String())
+	}
+
+	return ret
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Returns a function that undoes the changes LS_COLORS and theme files make to the colors, type lists and icons
+func saveColors() func() {
+	oldColors, oldSources, oldExtensionColors := maps.Clone(colors), maps.Clone(colorSources), maps.Clone(extensionColors)
+	oldSymlinkColorIsTargetSource := symlinkColorIsTargetSource
+	oldIcons, oldExtensionIcons := maps.Clone(icons), maps.Clone(extensionIcons)
+	oldTypes := make(map[string][]string)
+	for category, types := range categoryTypes {
+		oldTypes[category] = slices.Clone(*types)
+	}
+
+	return func() {
+		colors, colorSources, extensionColors = oldColors, oldSources, oldExtensionColors
+		symlinkColorIsTargetSource = oldSymlinkColorIsTargetSource
+		icons, extensionIcons = oldIcons, oldExtensionIcons
+		for category, types := range categoryTypes {
+			*types = oldTypes[category]
+		}
+	}
+}
+
+func TestParseLSColors(t *testing.T) {
+	type TestCase struct {
+		lsColors      string
+		key           string // A key in the colors map, or a suffix like ".log"
+		expected      string
+		expectedError string // Part of the error, "" for no error
+	}
+
+	tests := []TestCase{
+		{"di=01;34", "directory", "\x1b[01;34m", ""},
+		{"ln=01;36", "symlink_directory", "\x1b[01;36m", ""},
+		{"fi=00:ex=01;32", "executable", "\x1b[01;32m", ""},
+		{"*.LOG=33", ".log", "\x1b[33m", ""},
+		{"rs=0:ca=30;41:so=35", "socket", "\x1b[35m", ""},
+		{"::or=31::", "symlink_broken", "\x1b[31m", ""},
+		{"di=", "directory", "", ""},
+		{"di=bold", "directory", colors["directory"], "invalid color"},
+		{"di", "directory", colors["directory"], "missing '='"},
+		{"*=31", "directory", colors["directory"], "empty suffix"},
+	}
+	for _, test := range tests {
+		restoreColors := saveColors()
+
+		err := ParseLSColors(test.lsColors)
+		result := colors[test.key]
+		if strings.HasPrefix(test.key, ".") {
+			result = extensionColors[test.key].color
+		}
+		restoreColors()
+
+		if (err == nil) != (test.expectedError == "") || (err != nil && !strings.Contains(err.Error(), test.expectedError)) {
+			t.Fatal("Expected the error", test.expectedError, "for", test.lsColors, "but got", err)
+		}
+		if result != test.expected {
+			t.Fatal("Expected", strings.ReplaceAll(test.expected, "\x1b", "ESC"), "for", test.key, "in", test.lsColors, "but got", strings.ReplaceAll(result, "\x1b", "ESC"))
+		}
+	}
+}
+
+func TestParseThemeFile(t *testing.T) {
+	defer saveColors()()
+
+	path := filepath.Join(t.TempDir(), "ls-colors")
+	os.WriteFile(path, []byte("# A comment\n\n di = 01;35 \n*.tmp=90\nln=target\nimage+=.HEIC .avif\nicon.directory=D\nicon.*.tmp=T\n"), 0o644)
+
+	if err := ParseThemeFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if colors["directory"] != "\x1b[01;35m" || colorSources["directory"] != path+":3" {
+		t.Fatal("Expected the directory color from line 3, but got", colors["directory"], colorSources["directory"])
+	}
+	if rule, ok := MatchExtensionColor("x.tmp"); !ok || rule.color != "\x1b[90m" {
+		t.Fatal("Expected a color for .tmp files, but got", rule, ok)
+	}
+	if symlinkColorIsTargetSource != path+":5" {
+		t.Fatal("Expected symlinks to be colored like their targets, but got", symlinkColorIsTargetSource)
+	}
+	if !slices.Contains(imageTypes, ".heic") || !slices.Contains(imageTypes, ".avif") {
+		t.Fatal("Expected .heic and .avif to be added to the image types")
+	}
+	if icons["directory"] != "D" || extensionIcons[".tmp"] != "T" {
+		t.Fatal("Expected the icons from the theme file, but got", icons["directory"], extensionIcons[".tmp"])
+	}
+
+	type TestCase struct {
+		contents      string
+		expectedError string
+	}
+
+	tests := []TestCase{
+		{"di=01;34\nnot a rule\n", path + ":2: missing '='"},
+		{"sound+=.xyz\n", path + ":1: unknown file type \"sound\""},
+		{"di=blue\n", path + ":1: invalid color"},
+		{"icon.nope=x\n", path + ":1: unknown file type \"nope\" for icon"},
+	}
+	for _, test := range tests {
+		os.WriteFile(path, []byte(test.contents), 0o644)
+		err := ParseThemeFile(path)
+		if err == nil || !strings.HasPrefix(err.Error(), test.expectedError) {
+			t.Fatal("Expected the error", test.expectedError, "but got", err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var validQuotingStyleValues = [...]string{
+	"literal",
+	"shell",
+	"shell-escape",
+	"c",
+	"escape",
+}
+
+// Set when the output is a terminal, the "literal" and "shell" styles then show unprintable characters as '?' like GNU ls
+var hideControlChars = false
+
+// Replaces each unprintable character with '?'
+func hideUnprintable(str string) string {
+	var ret strings.Builder
+	for i := 0; i < len(str); {
+		_, size, printable := nextPrintable(str[i:])
+		if printable {
+			ret.WriteString(str[i : i+size])
+		} else {
+			ret.WriteByte('?')
+		}
+		i += size
+	}
+	return ret.String()
+}
+
+// Characters that never need quoting in a shell
+func isShellSafe(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || strings.ContainsRune("%+,-./:=@_^", r)
+}
+
+// Returns the byte at the start of str, and whether it is printable as-is
+func nextPrintable(str string) (r rune, size int, printable bool) {
+	r, size = utf8.DecodeRuneInString(str)
+	if r == utf8.RuneError && size <= 1 {
+		return r, size, false
+	}
+
+	return r, size, unicode.IsPrint(r)
+}
+
+// Returns the C escape sequence for the character, like "\n", or an octal escape like "\001"
+func cEscape(str string, size int) string {
+	if size == 1 {
+		switch str[0] {
+		case '\a':
+			return `\a`
+		case '\b':
+			return `\b`
+		case '\f':
+			return `\f`
+		case '\n':
+			return `\n`
+		case '\r':
+			return `\r`
+		case '\t':
+			return `\t`
+		case '\v':
+			return `\v`
+		}
+	}
+
+	var ret strings.Builder
+	for i := 0; i < size; i++ {
+		octal := strconv.FormatUint(uint64(str[i]), 8)
+		ret.WriteString(`\` + strings.Repeat("0", 3-len(octal)) + octal)
+	}
+	return ret.String()
+}
+
+// Single-quotes str for a shell, unless it only contains safe characters
+func shellQuote(str string, forceQuotes bool) string {
+	if !forceQuotes && str != "" && strings.IndexFunc(str, func(r rune) bool { return !isShellSafe(r) }) == -1 {
+		return str
+	}
+
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+// Quotes or escapes a file name like GNU ls --quoting-style.
+// "literal" leaves it as-is, unless hideControlChars is set
+func QuoteName(name string, style string) string {
+	if hideControlChars && (style == "literal" || style == "shell") {
+		name = hideUnprintable(name)
+	}
+
+	switch style {
+	case "shell":
+		return shellQuote(name, false)
+	case "shell-escape":
+		// Printable parts are single-quoted, the rest are in $'' escapes like 'new'$'\n''line'
+		var ret strings.Builder
+		var printableRun strings.Builder
+		hasUnprintable := false
+
+		for i := 0; i < len(name); {
+			_, size, printable := nextPrintable(name[i:])
+			if printable {
+				printableRun.WriteString(name[i : i+size])
+			} else {
+				if printableRun.Len() > 0 {
+					ret.WriteString(shellQuote(printableRun.String(), true))
+					printableRun.Reset()
+				}
+				ret.WriteString("$'" + cEscape(name[i:], size) + "'")
+				hasUnprintable = true
+			}
+			i += size
+		}
+
+		if !hasUnprintable {
+			return shellQuote(name, false)
+		}
+
+		if printableRun.Len() > 0 {
+			ret.WriteString(shellQuote(printableRun.String(), true))
+		}
+		return ret.String()
+	case "c", "escape":
+		var ret strings.Builder
+		if style == "c" {
+			ret.WriteByte('"')
+		}
+
+		for i := 0; i < len(name); {
+			r, size, printable := nextPrintable(name[i:])
+			switch {
+			case !printable:
+				ret.WriteString(cEscape(name[i:], size))
+			case r == '\\':
+				ret.WriteString(`\\`)
+			case r == '"' && style == "c":
+				ret.WriteString(`\"`)
+			case r == ' ' && style == "escape":
+				ret.WriteString(`\ `)
+			default:
+				ret.WriteString(name[i : i+size])
+			}
+			i += size
+		}
+
+		if style == "c" {
+			ret.WriteByte('"')
+		}
+		return ret.String()
+	}
+
+	return name
+}
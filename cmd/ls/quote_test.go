@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQuoteName(t *testing.T) {
+	type TestCase struct {
+		name     string
+		style    string
+		expected string
+	}
+
+	tests := []TestCase{
+		{"file.txt", "literal", "file.txt"},
+		{"new\nline", "literal", "new\nline"},
+
+		{"file.txt", "shell", "file.txt"},
+		{"a b", "shell", "'a b'"},
+		{"it's", "shell", `'it'\''s'`},
+
+		{"file.txt", "shell-escape", "file.txt"},
+		{"a b", "shell-escape", "'a b'"},
+		{"new\nline", "shell-escape", `'new'$'\n''line'`},
+		{"\x1b[31mred", "shell-escape", `$'\033''[31mred'`},
+		{"bad\xff", "shell-escape", `'bad'$'\377'`},
+		{"æøå", "shell-escape", "'æøå'"},
+
+		{"a b", "c", `"a b"`},
+		{"say \"hi\"\t", "c", `"say \"hi\"\t"`},
+		{`back\slash`, "c", `"back\\slash"`},
+
+		{"a b\n", "escape", `a\ b\n`},
+	}
+	for _, test := range tests {
+		result := QuoteName(test.name, test.style)
+		if result != test.expected {
+			t.Fatal("Expected: " + test.expected + " but got: " + result + " (style " + test.style + ")")
+		}
+	}
+}
+
+func TestQuoteNameInTerminal(t *testing.T) {
+	hideControlChars = true
+	defer func() { hideControlChars = false }()
+
+	type TestCase struct {
+		name     string
+		style    string
+		expected string
+	}
+
+	tests := []TestCase{
+		{"new\nline", "literal", "new?line"},
+		{"new\nline", "shell", "'new?line'"},
+		{"\x1b[31mred", "shell", "'?[31mred'"},
+		{"bad\xff", "shell", "'bad?'"},
+		{"æøå", "shell", "'æøå'"},
+		{"file.txt", "shell", "file.txt"},
+
+		// The escaping styles are already safe
+		{"new\nline", "shell-escape", `'new'$'\n''line'`},
+		{"\x1b[31mred", "c", `"\033[31mred"`},
+	}
+	for _, test := range tests {
+		result := QuoteName(test.name, test.style)
+		if result != test.expected {
+			t.Fatal("Expected: " + test.expected + " but got: " + result + " (style " + test.style + ")")
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The directories found by --recursive
+type DirectoryTree struct {
+	root     string
+	children map[string][]Entry // Entries of each directory, keyed by the directory path
+	errors   map[string]error   // Directories that could not be read
+}
+
+// Walks root without sorting, hidden directories are not descended into unless --all is used.
+// Entries removed by FilterEntries() are left out, and not descended into
+func WalkDirectoryTree(root string, options Options) DirectoryTree {
+	tree := DirectoryTree{
+		root:     filepath.Clean(root),
+		children: make(map[string][]Entry),
+		errors:   make(map[string]error),
+	}
+
+	myWalkDir(tree.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			tree.errors[path] = err
+			return nil
+		}
+
+		if path == tree.root {
+			return nil
+		}
+
+		entry := Entry{d, path}
+		if IsFiltered(entry, options) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		parent := strings.TrimSuffix(path, string(os.PathSeparator)+d.Name())
+		tree.children[parent] = append(tree.children[parent], entry)
+
+		if d.IsDir() && !options.all && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return tree
+}
+
+// Returns every entry in the tree, used for --summary
+func (tree DirectoryTree) AllEntries() []Entry {
+	var ret []Entry
+	for _, entries := range tree.children {
+		ret = append(ret, entries...)
+	}
+	return ret
+}
+
+// Prints each directory with a "dir:" header, in the same order as the entries are listed
+func PrintDirectoryTree(tree DirectoryTree, options Options, printSeparator bool) {
+	var printDirectory func(dir string)
+	printDirectory = func(dir string) {
+		// The path of each entry is enough to tell the directories apart in JSON
+		if options.format == "text" {
+			if printSeparator {
+				fmt.Print(options.lineTerminator)
+			}
+			printSeparator = true
+
+			fmt.Print(QuoteName(dir, options.quotingStyle) + ":" + options.lineTerminator)
+		}
+
+		if err, failed := tree.errors[dir]; failed {
+			// Like GNU ls, a directory given as an argument is a serious problem
+			status := exitMinorProblem
+			if dir == tree.root {
+				status = exitSeriousProblem
+			}
+			reportError("Failed to read directory", dir, err, status, options)
+		}
+
+		entries := OrderEntries(tree.children[dir], options)
+		PrintGroupedEntries(entries, options)
+
+		for _, e := range entries {
+			if !e.IsDir() || (!options.all && strings.HasPrefix(e.Name(), ".")) {
+				continue
+			}
+
+			printDirectory(e.path)
+		}
+	}
+
+	printDirectory(tree.root)
+}
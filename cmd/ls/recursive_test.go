@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecursiveListing(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b/inner", "a/deeper/file", ".hidden/secret", "top"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, nil, 0o644)
+	}
+	os.Mkdir(filepath.Join(dir, "empty"), 0o755)
+
+	type TestCase struct {
+		all      bool
+		expected string // With dir as "D"
+	}
+
+	tests := []TestCase{
+		// Each directory after the entries it is listed in, in the order they are sorted
+		{false, "D:|a|b|empty|top||D/a:|deeper||D/a/deeper:|file||D/b:|inner||D/empty:|"},
+		{true, "D:|.hidden|a|b|empty|top||D/.hidden:|secret||D/a:|deeper||D/a/deeper:|file||D/b:|inner||D/empty:|"},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.all = test.all
+		stdout, _, _ := captureOutput(t, func() {
+			entries, trees := CollectEntries([]string{dir}, false, true, options)
+			PrintListing(entries, trees, options)
+		})
+
+		result := strings.ReplaceAll(strings.ReplaceAll(stdout, dir, "D"), string(os.PathSeparator), "/")
+		result = strings.ReplaceAll(result, "\n", "|")
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "with all", test.all, "but got", result)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// At most this many bytes are read from each file, enough for the tar header at offset 257
+const sniffLength = 512
+
+type magicNumber struct {
+	offset   int
+	magic    []byte
+	category string
+}
+
+// https://en.wikipedia.org/wiki/List_of_file_signatures
+var magicNumbers = []magicNumber{
+	{0, []byte("\x89PNG\r\n\x1a\n"), "image"},
+	{0, []byte{0xff, 0xd8, 0xff}, "image"}, // JPEG
+	{0, []byte("GIF87a"), "image"},
+	{0, []byte("GIF89a"), "image"},
+	{8, []byte("WEBP"), "image"},
+	{0, []byte("II*\x00"), "image"}, // TIFF, little-endian
+	{0, []byte("MM\x00*"), "image"}, // TIFF, big-endian
+	{4, []byte("ftypheic"), "image"},
+	{4, []byte("ftypavif"), "image"},
+
+	{4, []byte("ftyp"), "video"},                 // MP4, MOV and friends
+	{0, []byte{0x1a, 0x45, 0xdf, 0xa3}, "video"}, // Matroska, WebM
+
+	{0, []byte("ID3"), "audio"}, // MP3
+	{0, []byte("fLaC"), "audio"},
+	{0, []byte("OggS"), "audio"},
+	{8, []byte("WAVE"), "audio"},
+
+	{0, []byte{0x1f, 0x8b}, "archive"}, // gzip
+	{0, []byte("PK\x03\x04"), "archive"},
+	{0, []byte("PK\x05\x06"), "archive"}, // Empty zip
+	{0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, "archive"},
+	{0, []byte("BZh"), "archive"},
+	{0, []byte{0x28, 0xb5, 0x2f, 0xfd}, "archive"}, // zstd
+	{0, []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, "archive"},
+	{257, []byte("ustar"), "archive"}, // tar
+
+	{0, []byte("%PDF-"), "document"},
+
+	{0, []byte{0x7f, 'E', 'L', 'F'}, "executable"},
+	{0, []byte("#!"), "code"}, // Shebang script
+}
+
+// Returns the category of a file from its first bytes, or "" if unrecognized
+func SniffCategory(header []byte) string {
+	for _, m := range magicNumbers {
+		if len(header) >= m.offset+len(m.magic) && bytes.Equal(header[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.category
+		}
+	}
+
+	return ""
+}
+
+func sniffFile(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return ""
+	}
+
+	return SniffCategory(header[:n])
+}
+
+// Reads the start of the regular files not already recognized by their extension or mode,
+// spread across a worker per CPU, and stores the results in caches.sniffedCategories
+func SniffEntries(entries []Entry) {
+	var files []Entry
+	for _, e := range entries {
+		if _, ok := caches.sniffedCategories[e.path]; ok {
+			continue
+		}
+
+		if !e.Type().IsRegular() {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil || info.Size() == 0 || FileCategory(info, e.path) != "nothing" {
+			continue
+		}
+
+		files = append(files, e)
+	}
+
+	var mutex sync.Mutex
+	parallelForEntries(files, func(e Entry) {
+		category := sniffFile(e.path)
+		mutex.Lock()
+		caches.sniffedCategories[e.path] = category
+		mutex.Unlock()
+	})
+}
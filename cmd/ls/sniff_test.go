@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSniffCategory(t *testing.T) {
+	tarHeader := make([]byte, 512)
+	copy(tarHeader[257:], "ustar")
+
+	type TestCase struct {
+		header   []byte
+		expected string
+	}
+
+	tests := []TestCase{
+		{[]byte("\x89PNG\r\n\x1a\nrest"), "image"},
+		{[]byte{0xff, 0xd8, 0xff, 0xe0}, "image"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "image"},
+		{[]byte("RIFF\x00\x00\x00\x00WAVEfmt "), "audio"},
+		{[]byte("\x00\x00\x00\x18ftypheic"), "image"},
+		{[]byte("\x00\x00\x00\x18ftypisom"), "video"},
+		{[]byte("PK\x03\x04"), "archive"},
+		{tarHeader, "archive"},
+		{tarHeader[:260], ""}, // Too short for the tar magic
+		{[]byte("%PDF-1.7"), "document"},
+		{[]byte("\x7fELF\x02\x01"), "executable"},
+		{[]byte("#!/bin/sh\n"), "code"},
+		{[]byte("plain text"), ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		result := SniffCategory(test.header)
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.header, "but got", result)
+		}
+	}
+}
+
+func TestSniffEntries(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "picture"), []byte("\x89PNG\r\n\x1a\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "script"), []byte("#!/bin/sh\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes"), []byte("plain text"), 0o644)
+	// The extension wins over the contents
+	os.WriteFile(filepath.Join(dir, "fake.pdf"), []byte("\x89PNG\r\n\x1a\n"), 0o644)
+	defer clearCaches()
+
+	entries, _ := CollectEntries([]string{dir}, false, false, testOptions())
+	SniffEntries(entries)
+
+	type TestCase struct {
+		name     string
+		expected string
+	}
+
+	tests := []TestCase{
+		{"picture", "image"},
+		{"script", "code"},
+		{"notes", "nothing"},
+		{"fake.pdf", "document"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		stat, _ := os.Lstat(path)
+		result := FileCategory(stat, path)
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.name, "but got", result)
+		}
+	}
+}
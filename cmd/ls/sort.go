@@ -0,0 +1,222 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var validSortByValues = [...]string{
+	"none",
+	"name",
+	"extension",
+	"version",
+	"size",
+	"modified",
+	"accessed",
+	"changed",
+	"time", // The timestamp chosen with --time
+	"git",  // Untracked, then modified, then clean
+}
+
+var validGroupByValues = [...]string{
+	"none",
+	"git", // The same groups as --sort-by=git
+}
+
+// Compares strings like a human would, so that "file2" comes before "file10".
+// Runs of digits are compared by their numeric value, everything else byte by byte
+func NaturalCompare(a, b string) int {
+	isDigit := func(c byte) bool {
+		return c >= '0' && c <= '9'
+	}
+
+	// Returns the index right after the run of digits (or non-digits) starting at i
+	runEnd := func(str string, i int) int {
+		digit := isDigit(str[i])
+		for i < len(str) && isDigit(str[i]) == digit {
+			i++
+		}
+		return i
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		aEnd := runEnd(a, i)
+		bEnd := runEnd(b, j)
+		aRun := a[i:aEnd]
+		bRun := b[j:bEnd]
+
+		if isDigit(a[i]) && isDigit(b[j]) {
+			aTrimmed := strings.TrimLeft(aRun, "0")
+			bTrimmed := strings.TrimLeft(bRun, "0")
+
+			// More digits means a larger number
+			if len(aTrimmed) != len(bTrimmed) {
+				if len(aTrimmed) < len(bTrimmed) {
+					return -1
+				}
+				return 1
+			}
+
+			if c := strings.Compare(aTrimmed, bTrimmed); c != 0 {
+				return c
+			}
+
+			// Same number, fewer leading zeros first
+			if len(aRun) != len(bRun) {
+				if len(aRun) < len(bRun) {
+					return -1
+				}
+				return 1
+			}
+		} else if c := strings.Compare(aRun, bRun); c != 0 {
+			return c
+		}
+
+		i = aEnd
+		j = bEnd
+	}
+
+	// The shorter remainder is a prefix of the other
+	aRemaining := len(a) - i
+	bRemaining := len(b) - j
+	if aRemaining < bRemaining {
+		return -1
+	} else if aRemaining > bRemaining {
+		return 1
+	}
+	return 0
+}
+
+// Returns the file extension without treating a leading '.' (hidden files) as one
+func sortExtension(name string) string {
+	return filepath.Ext(strings.TrimPrefix(name, "."))
+}
+
+func compareTimes(a, b time.Time) int {
+	if a.Before(b) {
+		return -1
+	}
+
+	if a.Equal(b) {
+		return 0
+	}
+
+	return 1
+}
+
+// The --time each of these sorts by, "time" uses the one given with --time
+var sortByTimeFields = map[string]string{
+	"modified": "mtime",
+	"accessed": "atime",
+	"changed":  "ctime",
+}
+
+// An entry with its stat, so each entry is only stat'ed once while sorting
+type sortItem struct {
+	entry    Entry
+	info     os.FileInfo
+	size     uint64    // From caches.entrySizes when using --dir-sizes
+	time     time.Time // Zero if unavailable
+	gitGroup int       // Index into gitGroupNames, for sortBy "git"
+	err      error
+}
+
+// Sorts in ascending order. Entries that fail to stat are put last when sorting by stat values.
+// timeField is the timestamp used for sortBy "time", see EntryTime()
+func SortEntries(entries *[]Entry, sortBy string, timeField string, ignoreCase bool) {
+	if sortBy == "none" {
+		return
+	}
+
+	var detailedStatus map[string]GitDetailedStatus
+	if sortBy == "git" {
+		detailedStatus = GitDetailedStatusOfEntries(*entries, false)
+	}
+
+	items := make([]sortItem, len(*entries))
+	for i, e := range *entries {
+		items[i].entry = e
+		items[i].gitGroup = gitGroupOf(detailedStatus, e.path)
+
+		switch sortBy {
+		case "size", "modified", "accessed", "changed", "time":
+			items[i].info, items[i].err = e.Info()
+			if items[i].err == nil && sortBy == "size" {
+				size, ok := caches.entrySizes[e.path]
+				if !ok {
+					size = uint64(items[i].info.Size())
+				}
+				items[i].size = size
+			}
+
+			if items[i].err == nil && sortBy != "size" {
+				field, ok := sortByTimeFields[sortBy]
+				if !ok {
+					field = timeField
+				}
+				items[i].time, _ = EntryTime(items[i].info, e.path, field)
+			}
+		}
+	}
+
+	compareNames := func(a, b string) int {
+		if ignoreCase {
+			if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+				return c
+			}
+		}
+
+		return strings.Compare(a, b)
+	}
+
+	slices.SortStableFunc(items, func(a, b sortItem) int {
+		if (a.err != nil) != (b.err != nil) {
+			if a.err != nil {
+				return 1
+			}
+			return -1
+		}
+
+		if a.err != nil {
+			return compareNames(a.entry.Name(), b.entry.Name())
+		}
+
+		switch sortBy {
+		case "name":
+			return compareNames(a.entry.Name(), b.entry.Name())
+		case "extension":
+			if c := compareNames(sortExtension(a.entry.Name()), sortExtension(b.entry.Name())); c != 0 {
+				return c
+			}
+			return compareNames(a.entry.Name(), b.entry.Name())
+		case "version":
+			if ignoreCase {
+				if c := NaturalCompare(strings.ToLower(a.entry.Name()), strings.ToLower(b.entry.Name())); c != 0 {
+					return c
+				}
+			}
+			return NaturalCompare(a.entry.Name(), b.entry.Name())
+		case "size":
+			if a.size < b.size {
+				return -1
+			} else if a.size > b.size {
+				return 1
+			}
+			return 0
+		case "modified", "accessed", "changed", "time":
+			return compareTimes(a.time, b.time)
+		case "git":
+			return a.gitGroup - b.gitGroup
+		}
+
+		return 0
+	})
+
+	for i, item := range items {
+		(*entries)[i] = item.entry
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	type TestCase struct {
+		a        string
+		b        string
+		expected int
+	}
+
+	tests := []TestCase{
+		{"", "", 0},
+		{"a", "a", 0},
+		{"a", "b", -1},
+		{"file2", "file10", -1},
+		{"file10", "file2", 1},
+		{"file02", "file2", 1},
+		{"file2", "file2.txt", -1},
+		{"v1.9.0", "v1.10.0", -1},
+		{"a1b2", "a1b10", -1},
+		{"abc", "ab1", 1},
+		{"007", "7", 1},
+	}
+	for _, test := range tests {
+		result := NaturalCompare(test.a, test.b)
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "comparing \""+test.a+"\" and \""+test.b+"\" but got", result)
+		}
+	}
+}
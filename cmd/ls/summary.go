@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kivattt/gogitstatus"
+)
+
+type SummaryLargestFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+type SummaryGit struct {
+	Untracked int `json:"untracked"`
+	Modified  int `json:"modified"`
+}
+
+// The stats shown by --summary
+type Summary struct {
+	Folders       int                 `json:"folders"`
+	HiddenFolders int                 `json:"hidden_folders"`
+	Files         int                 `json:"files"`
+	HiddenFiles   int                 `json:"hidden_files"`
+	Total         int                 `json:"total"`
+	Symlinks      int                 `json:"symlinks"`
+	Executables   int                 `json:"executables"`
+	TotalSize     int64               `json:"total_size"` // Sum of the file sizes, not including directories
+	LargestFile   *SummaryLargestFile `json:"largest_file"`
+	Categories    map[string]int      `json:"categories"` // Keyed by the keys in the colors map, see FileCategory()
+	Git           *SummaryGit         `json:"git,omitempty"`
+}
+
+// changedOrUntracked should be nil if --git-status is not used
+func GetSummary(entries []Entry, changedOrUntracked map[string]gogitstatus.ChangedFile) Summary {
+	summary := Summary{Categories: make(map[string]int)}
+
+	if changedOrUntracked != nil {
+		summary.Git = &SummaryGit{}
+	}
+
+	for _, e := range entries {
+		isHidden := strings.HasPrefix(e.Name(), ".")
+
+		if e.IsDir() {
+			if isHidden {
+				summary.HiddenFolders++
+			} else {
+				summary.Folders++
+			}
+		} else {
+			if isHidden {
+				summary.HiddenFiles++
+			} else {
+				summary.Files++
+			}
+		}
+
+		if changedFile, ok := changedOrUntracked[e.path]; ok {
+			if changedFile.Untracked {
+				summary.Git.Untracked++
+			} else {
+				summary.Git.Modified++
+			}
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		category := FileCategory(info, e.path)
+		summary.Categories[category]++
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			summary.Symlinks++
+		}
+
+		if info.Mode().IsRegular() {
+			if info.Mode()&0111 != 0 {
+				summary.Executables++
+			}
+
+			summary.TotalSize += info.Size()
+			if summary.LargestFile == nil || info.Size() > summary.LargestFile.Size {
+				summary.LargestFile = &SummaryLargestFile{Path: e.path, Size: info.Size()}
+			}
+		}
+	}
+
+	summary.Total = summary.Folders + summary.HiddenFolders + summary.Files + summary.HiddenFiles
+	return summary
+}
+
+func PrintSummary(summary Summary, options Options) {
+	if options.format != "text" {
+		var data []byte
+		if options.format == "ndjson" {
+			data, _ = json.Marshal(summary)
+		} else {
+			data, _ = json.MarshalIndent(summary, "", "  ")
+		}
+		os.Stdout.Write(data)
+		os.Stdout.WriteString("\n")
+		return
+	}
+
+	fmt.Print(summary.Folders, " folders")
+	if summary.HiddenFolders > 0 {
+		fmt.Print(" (" + strconv.Itoa(summary.HiddenFolders) + " hidden)")
+	}
+	fmt.Println()
+
+	fmt.Print(summary.Files, " files")
+	if summary.HiddenFiles > 0 {
+		fmt.Print("   (" + strconv.Itoa(summary.HiddenFiles) + " hidden)")
+	}
+	fmt.Println()
+
+	fmt.Println(summary.Total, "total")
+	fmt.Println()
+
+	fmt.Println(summary.Symlinks, "symlinks")
+	fmt.Println(summary.Executables, "executables")
+	fmt.Println()
+
+	fmt.Println("total size:  ", BytesToHumanReadableUnitString(uint64(summary.TotalSize), 1), "("+strconv.FormatInt(summary.TotalSize, 10)+" bytes)")
+	if summary.LargestFile != nil {
+		fmt.Println("largest file:", BytesToHumanReadableUnitString(uint64(summary.LargestFile.Size), 1), QuoteName(summary.LargestFile.Path, options.quotingStyle))
+	}
+
+	var categories []string
+	longestCategory := 0
+	for category := range summary.Categories {
+		if category == "nothing" {
+			continue
+		}
+		categories = append(categories, category)
+		longestCategory = max(longestCategory, len(category))
+	}
+	slices.Sort(categories)
+
+	if len(categories) > 0 {
+		fmt.Println()
+	}
+
+	for _, category := range categories {
+		if options.colorEnabled {
+			fmt.Print(colors[category])
+		}
+		fmt.Print(category)
+		if options.colorEnabled {
+			fmt.Print("\x1b[0m")
+		}
+		fmt.Println(strings.Repeat(" ", 1+longestCategory-len(category)) + strconv.Itoa(summary.Categories[category]))
+	}
+
+	if summary.Git != nil {
+		fmt.Println()
+		fmt.Println(summary.Git.Untracked, "untracked")
+		fmt.Println(summary.Git.Modified, "modified")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/kivattt/gogitstatus"
+)
+
+func TestGetSummary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no execute bits")
+	}
+
+	dir := newTestRepository(t, map[string]string{"main.go": "package main", "edited.txt": "edited"})
+	os.WriteFile(filepath.Join(dir, "edited.txt"), []byte("changed"), 0o644)
+	os.WriteFile(filepath.Join(dir, "picture.png"), make([]byte, 1000), 0o644)
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("hi"), 0o644)
+	os.WriteFile(filepath.Join(dir, "run"), []byte("#!/bin/sh"), 0o755)
+	os.Chmod(filepath.Join(dir, "run"), 0o755)
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	defer clearCaches()
+
+	entries, _ := CollectEntries([]string{dir}, false, false, testOptions())
+
+	// .git is a hidden folder
+	expected := Summary{
+		Folders:       1,
+		HiddenFolders: 1,
+		Files:         4,
+		HiddenFiles:   1,
+		Total:         7,
+		Executables:   1,
+		TotalSize:     12 + 7 + 1000 + 2 + 9,
+		LargestFile:   &SummaryLargestFile{Path: filepath.Join(dir, "picture.png"), Size: 1000},
+		Categories:    map[string]int{"directory": 2, "code": 1, "document": 1, "image": 1, "nothing": 1, "executable": 1},
+	}
+	expectedWithGit := expected
+	expectedWithGit.Git = &SummaryGit{Untracked: 3, Modified: 1} // picture.png, .hidden and run are untracked
+
+	type TestCase struct {
+		changedOrUntracked map[string]gogitstatus.ChangedFile
+		expected           Summary
+	}
+
+	tests := []TestCase{
+		{nil, expected},
+		{GitStatusOfEntries(entries), expectedWithGit},
+	}
+	for _, test := range tests {
+		result := GetSummary(entries, test.changedOrUntracked)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Fatal("Expected", test.expected, "but got", result)
+		}
+	}
+}
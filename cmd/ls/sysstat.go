@@ -0,0 +1,38 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Returns the platform-specific fields of stat, ok is false if they are unavailable
+func GetSysStat(stat os.FileInfo) (sysStat SysStat, ok bool) {
+	unixStat, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return sysStat, false
+	}
+
+	sysStat.uid = unixStat.Uid
+	sysStat.gid = unixStat.Gid
+	sysStat.nlink = uint64(unixStat.Nlink)
+	sysStat.device = uint64(unixStat.Dev)
+	sysStat.inode = uint64(unixStat.Ino)
+	sysStat.blocks = uint64(unixStat.Blocks)
+	sysStat.rdev = uint64(unixStat.Rdev)
+	sysStat.accessed = time.Unix(int64(unixStat.Atimespec.Sec), int64(unixStat.Atimespec.Nsec))
+	sysStat.changed = time.Unix(int64(unixStat.Ctimespec.Sec), int64(unixStat.Ctimespec.Nsec))
+	return sysStat, true
+}
+
+func deviceMajor(rdev uint64) uint32 {
+	return unix.Major(rdev)
+}
+
+func deviceMinor(rdev uint64) uint32 {
+	return unix.Minor(rdev)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+)
+
+// Returns the platform-specific fields of stat, ok is false if they are unavailable
+func GetSysStat(stat os.FileInfo) (sysStat SysStat, ok bool) {
+	return sysStat, false
+}
+
+// Windows has no device files
+func deviceMajor(rdev uint64) uint32 {
+	return 0
+}
+
+func deviceMinor(rdev uint64) uint32 {
+	return 0
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var validTimeValues = [...]string{
+	"mtime",
+	"atime",
+	"ctime",
+	"birth",
+}
+
+// Custom formats start with '+', like "+%Y-%m-%d"
+var validTimeStyleValues = [...]string{
+	"default",
+	"iso",
+	"long-iso",
+	"full-iso",
+	"relative",
+}
+
+// Returns the timestamp chosen with --time.
+// ok is false if it is unavailable, like the birth time on some filesystems
+func EntryTime(stat os.FileInfo, path string, timeField string) (t time.Time, ok bool) {
+	switch timeField {
+	case "atime", "ctime":
+		sysStat, ok := GetSysStat(stat)
+		if !ok {
+			// Fall back to the modification time where these are unavailable
+			return stat.ModTime(), true
+		}
+
+		if timeField == "atime" {
+			return sysStat.accessed, true
+		}
+		return sysStat.changed, true
+	case "birth":
+		return BirthTime(stat, path)
+	}
+
+	return stat.ModTime(), true
+}
+
+// Returns t like "3 minutes ago", or "in 2 hours" if it is in the future
+func FormatRelativeTime(t time.Time, now time.Time) string {
+	duration := now.Sub(t)
+	future := duration < 0
+	if future {
+		duration = -duration
+	}
+
+	if duration < time.Second {
+		return "just now"
+	}
+
+	units := []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+		{"second", time.Second},
+	}
+
+	var ret string
+	for _, unit := range units {
+		n := int64(duration / unit.duration)
+		if n == 0 {
+			continue
+		}
+
+		ret = strconv.FormatInt(n, 10) + " " + unit.name
+		if n != 1 {
+			ret += "s"
+		}
+		break
+	}
+
+	if future {
+		return "in " + ret
+	}
+	return ret + " ago"
+}
+
+// Formats t with a strftime(3) format like "%Y-%m-%d %H:%M", as used by date and GNU ls --time-style.
+// %N is nanoseconds, unknown conversions are kept as-is
+func Strftime(t time.Time, format string) string {
+	var ret strings.Builder
+
+	pad := func(n int, width int) {
+		str := strconv.Itoa(n)
+		ret.WriteString(strings.Repeat("0", max(0, width-len(str))) + str)
+	}
+
+	spacePad := func(n int, width int) {
+		str := strconv.Itoa(n)
+		ret.WriteString(strings.Repeat(" ", max(0, width-len(str))) + str)
+	}
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			ret.WriteByte(format[i])
+			continue
+		}
+
+		i++
+		switch format[i] {
+		case 'Y':
+			pad(t.Year(), 4)
+		case 'C':
+			pad(t.Year()/100, 2)
+		case 'y':
+			pad(t.Year()%100, 2)
+		case 'm':
+			pad(int(t.Month()), 2)
+		case 'd':
+			pad(t.Day(), 2)
+		case 'e':
+			spacePad(t.Day(), 2)
+		case 'j':
+			pad(t.YearDay(), 3)
+		case 'H':
+			pad(t.Hour(), 2)
+		case 'k':
+			spacePad(t.Hour(), 2)
+		case 'I', 'l':
+			hour := t.Hour() % 12
+			if hour == 0 {
+				hour = 12
+			}
+			if format[i] == 'I' {
+				pad(hour, 2)
+			} else {
+				spacePad(hour, 2)
+			}
+		case 'M':
+			pad(t.Minute(), 2)
+		case 'S':
+			pad(t.Second(), 2)
+		case 'N':
+			pad(t.Nanosecond(), 9)
+		case 'p':
+			ret.WriteString(t.Format("PM"))
+		case 'b', 'h':
+			ret.WriteString(t.Format("Jan"))
+		case 'B':
+			ret.WriteString(t.Format("January"))
+		case 'a':
+			ret.WriteString(t.Format("Mon"))
+		case 'A':
+			ret.WriteString(t.Format("Monday"))
+		case 'u':
+			weekday := int(t.Weekday())
+			if weekday == 0 {
+				weekday = 7
+			}
+			ret.WriteString(strconv.Itoa(weekday))
+		case 'w':
+			ret.WriteString(strconv.Itoa(int(t.Weekday())))
+		case 'z':
+			ret.WriteString(t.Format("-0700"))
+		case 'Z':
+			ret.WriteString(t.Format("MST"))
+		case 's':
+			ret.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'F':
+			ret.WriteString(Strftime(t, "%Y-%m-%d"))
+		case 'T':
+			ret.WriteString(Strftime(t, "%H:%M:%S"))
+		case 'R':
+			ret.WriteString(Strftime(t, "%H:%M"))
+		case 'D':
+			ret.WriteString(Strftime(t, "%m/%d/%y"))
+		case 'n':
+			ret.WriteByte('\n')
+		case 't':
+			ret.WriteByte('\t')
+		case '%':
+			ret.WriteByte('%')
+		default:
+			ret.WriteString("%" + string(format[i]))
+		}
+	}
+
+	return ret.String()
+}
+
+// Formats the time of a file in --long mode with a --time-style.
+// Like GNU ls, the default and iso styles show the year instead of the time of day if t is older than 6 months or in the future
+func FormatTime(t time.Time, timeStyle string, now time.Time) string {
+	recent := !t.Before(now.AddDate(0, -6, 0)) && !t.After(now)
+
+	switch timeStyle {
+	case "iso":
+		if recent {
+			return Strftime(t, "%m-%d %H:%M")
+		}
+		return Strftime(t, "%Y-%m-%d ")
+	case "long-iso":
+		return Strftime(t, "%Y-%m-%d %H:%M")
+	case "full-iso":
+		return Strftime(t, "%Y-%m-%d %H:%M:%S.%N %z")
+	case "relative":
+		return FormatRelativeTime(t, now)
+	}
+
+	if format, custom := strings.CutPrefix(timeStyle, "+"); custom {
+		return Strftime(t, format)
+	}
+
+	return FormatModifiedTime(t, now)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStrftime(t *testing.T) {
+	type TestCase struct {
+		format   string
+		expected string
+	}
+
+	date := time.Date(2024, time.March, 5, 14, 7, 9, 1234, time.UTC)
+
+	tests := []TestCase{
+		{"", ""},
+		{"%Y-%m-%d", "2024-03-05"},
+		{"%F %T", "2024-03-05 14:07:09"},
+		{"%e %b %y", " 5 Mar 24"},
+		{"%I:%M %p", "02:07 PM"},
+		{"%N", "000001234"},
+		{"%j %a %u", "065 Tue 2"},
+		{"100%%", "100%"},
+		{"%Q", "%Q"},
+		{"trailing %", "trailing %"},
+	}
+	for _, test := range tests {
+		result := Strftime(date, test.format)
+		if result != test.expected {
+			t.Fatal("Expected \"" + test.expected + "\" for format \"" + test.format + "\" but got \"" + result + "\"")
+		}
+	}
+}
+
+func TestFormatRelativeTime(t *testing.T) {
+	type TestCase struct {
+		ago      time.Duration
+		expected string
+	}
+
+	tests := []TestCase{
+		{0, "just now"},
+		{time.Second, "1 second ago"},
+		{3 * time.Minute, "3 minutes ago"},
+		{90 * time.Minute, "1 hour ago"},
+		{-2 * time.Hour, "in 2 hours"},
+		{8 * 24 * time.Hour, "1 week ago"},
+		{400 * 24 * time.Hour, "1 year ago"},
+	}
+
+	now := time.Now()
+	for _, test := range tests {
+		result := FormatRelativeTime(now.Add(-test.ago), now)
+		if result != test.expected {
+			t.Fatal("Expected \"" + test.expected + "\" but got \"" + result + "\"")
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kivattt/gogitstatus"
+)
+
+const (
+	treeBranch     = "├── "
+	treeLastBranch = "└── "
+	treeIndent     = "│   "
+	treeLastIndent = "    "
+)
+
+// Identifies a directory, to detect symlinks leading back into a directory being listed
+type fileID struct {
+	device uint64
+	inode  uint64
+}
+
+func getFileID(stat os.FileInfo) (fileID, bool) {
+	sysStat, ok := GetSysStat(stat)
+	if !ok {
+		return fileID{}, false
+	}
+
+	return fileID{sysStat.device, sysStat.inode}, true
+}
+
+// Prints the directory at root and everything below it with ├── and └── connectors.
+// A depth of less than 0 means no limit
+func PrintTree(root string, depth int, follow bool, options Options) {
+	rootStat, err := os.Lstat(root)
+	if err != nil {
+		reportError("Failed to stat", root, err, exitSeriousProblem, options)
+		return
+	}
+
+	if options.colorEnabled {
+		fmt.Print(FileColor(rootStat, root))
+	}
+	quotedRoot := QuoteName(root, options.quotingStyle)
+	if options.hyperlink {
+		fmt.Print(Hyperlink(quotedRoot, root))
+	} else {
+		fmt.Print(quotedRoot)
+	}
+	if options.colorEnabled {
+		fmt.Print("\x1b[0m")
+	}
+	fmt.Print(options.lineTerminator)
+
+	// Sizes for every level come from one walk
+	if options.dirSizes {
+		ComputeTreeSizes(root, options.allocated)
+	}
+
+	// The directories currently being listed, from the root down
+	ancestors := make(map[fileID]bool)
+
+	var printDirectory func(dir string, prefix string, level int)
+	printDirectory = func(dir string, prefix string, level int) {
+		dirStat, err := os.Stat(dir)
+		if err == nil {
+			id, ok := getFileID(dirStat)
+			if ok {
+				ancestors[id] = true
+				defer delete(ancestors, id)
+			}
+		}
+
+		dirEntries, err := os.ReadDir(dir)
+		if err != nil {
+			status := exitMinorProblem
+			if dir == root {
+				status = exitSeriousProblem
+			}
+			reportError("Failed to read directory", dir, err, status, options)
+			return
+		}
+
+		var entries []Entry
+		for _, dirEntry := range dirEntries {
+			if !options.all && strings.HasPrefix(dirEntry.Name(), ".") {
+				continue
+			}
+			entries = append(entries, Entry{dirEntry, myJoin(dir, dirEntry.Name())})
+		}
+
+		entries = OrderEntries(FilterEntries(entries, options), options)
+
+		if options.sniff {
+			SniffEntries(entries)
+		}
+
+		var changedOrUntracked map[string]gogitstatus.ChangedFile
+		if options.gitStatus {
+			changedOrUntracked = GitStatusOfEntries(entries)
+		}
+
+		for i, e := range entries {
+			isLast := i == len(entries)-1
+
+			connector := treeBranch
+			childPrefix := prefix + treeIndent
+			if isLast {
+				connector = treeLastBranch
+				childPrefix = prefix + treeLastIndent
+			}
+
+			info, err := e.Info()
+			if err != nil {
+				reportError("Failed to stat", e.path, err, exitMinorProblem, options)
+				continue
+			}
+
+			// Like tree --du, the size goes before the name
+			size := ""
+			if options.dirSizes {
+				total, ok := caches.entrySizes[e.path]
+				if !ok {
+					total = fileSize(info, options.allocated)
+				}
+				size = "[" + FormatSize(total, options.humanReadable) + "] "
+			}
+
+			_, changed := changedOrUntracked[e.path]
+			line := prefix + connector + size + FormatName(e, info, changed, options)
+
+			descend := info.IsDir()
+			if info.Mode()&os.ModeSymlink != 0 {
+				target, targetPath, err := SymlinkTarget(e.path)
+				if err == nil {
+					line += FormatSymlinkTarget(target, targetPath, options)
+				}
+
+				if follow {
+					targetStat, err := os.Stat(e.path)
+					if err == nil && targetStat.IsDir() {
+						descend = true
+
+						id, ok := getFileID(targetStat)
+						if ok && ancestors[id] {
+							line += " [recursive, not followed]"
+							descend = false
+						}
+					}
+				}
+			}
+
+			fmt.Print(line + options.lineTerminator)
+
+			if descend && (depth < 0 || level < depth) {
+				printDirectory(e.path, childPrefix, level+1)
+			}
+		}
+	}
+
+	if rootStat.IsDir() || (follow && rootStat.Mode()&os.ModeSymlink != 0) {
+		if depth != 0 {
+			printDirectory(root, "", 1)
+		}
+	}
+}
@@ -1,17 +1,40 @@
 package main
 
 import (
+	"io/fs"
+	"math"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 )
 
+// A file to be listed, path is where it is found relative to the current working directory
+type Entry struct {
+	fs.DirEntry
+	path string
+}
+
 var colors = map[string]string{
 	"nothing":           "",
 	"directory":         "\x1b[1;34m", // Blue, Bold
 	"executable":        "\x1b[1;32m", // Green, Bold
 	"symlink_directory": "\x1b[1;36m", // Cyan, Bold
 	"symlink":           "\x1b[0;36m", // Cyan
+	"symlink_broken":    "\x1b[9;36m", // Cyan, Strikethrough
+	"missing":           "",           // Target of a broken symlink
+
+	// Same as the GNU ls defaults
+	"pipe":                  "\x1b[40;33m",   // Yellow on black
+	"socket":                "\x1b[1;35m",    // Magenta, Bold
+	"block_device":          "\x1b[40;33;1m", // Yellow on black, Bold
+	"char_device":           "\x1b[40;33;1m", // Yellow on black, Bold
+	"setuid":                "\x1b[37;41m",   // White on red
+	"setgid":                "\x1b[30;43m",   // Black on yellow
+	"sticky":                "\x1b[37;44m",   // White on blue
+	"other_writable":        "\x1b[34;42m",   // Blue on green
+	"sticky_other_writable": "\x1b[30;42m",   // Black on green
 
 	"image":    "\x1b[0;33m",             // Dark Yellow
 	"video":    "\x1b[38;2;255;105;180m", // Pink
@@ -109,11 +132,11 @@ var windowsExecutableTypes = []string{
 	".msi",
 }
 
+// Returns the key in colors which describes the file, e.g. "directory" or "image"
 // stat should be from an os.Lstat()
-func FileColor(stat os.FileInfo, path string) string {
+func FileCategory(stat os.FileInfo, path string) string {
 	if stat == nil {
-		return colors["nothing"]
-		//return tcell.StyleDefault
+		return "nothing"
 	}
 
 	hasSuffixFromList := func(str string, list []string) bool {
@@ -126,63 +149,119 @@ func FileColor(stat os.FileInfo, path string) string {
 		return false
 	}
 
+	mode := stat.Mode()
 	if stat.IsDir() {
-		return colors["directory"]
-		//return ret.Foreground(tcell.ColorBlue).Bold(true)
-	} else if stat.Mode().IsRegular() {
-		if stat.Mode()&0111 != 0 || (runtime.GOOS == "windows" && hasSuffixFromList(path, windowsExecutableTypes)) { // Executable file
-			return colors["executable"]
-			//return ret.Foreground(tcell.NewRGBColor(0, 255, 0)).Bold(true) // Green
+		otherWritable := mode&0002 != 0
+		sticky := mode&os.ModeSticky != 0
+		if sticky && otherWritable {
+			return "sticky_other_writable"
+		} else if otherWritable {
+			return "other_writable"
+		} else if sticky {
+			return "sticky"
+		}
+
+		return "directory"
+	} else if mode.IsRegular() {
+		// Only executables run as their owner or group
+		if mode&os.ModeSetuid != 0 && mode&0111 != 0 {
+			return "setuid"
+		}
+
+		if mode&os.ModeSetgid != 0 && mode&0111 != 0 {
+			return "setgid"
+		}
+
+		if mode&0111 != 0 || (runtime.GOOS == "windows" && hasSuffixFromList(path, windowsExecutableTypes)) { // Executable file
+			return "executable"
 		}
-	} else if stat.Mode()&os.ModeSymlink != 0 {
+	} else if mode&os.ModeSymlink != 0 {
 		targetStat, err := os.Stat(path)
-		if err == nil && targetStat.IsDir() {
-			return colors["symlink_directory"]
-			//return ret.Foreground(tcell.ColorTeal).Bold(true)
+		if archived, ok := stat.(*archiveFile); ok {
+			targetStat, err = archived.target, nil
+			if archived.target == nil {
+				err = os.ErrNotExist
+			}
+		}
+		if err != nil {
+			return "symlink_broken"
+		}
+
+		if targetStat.IsDir() {
+			return "symlink_directory"
 		}
 
-		return colors["symlink"]
-		//return ret.Foreground(tcell.ColorTeal)
+		return "symlink"
+	} else if mode&os.ModeNamedPipe != 0 {
+		return "pipe"
+	} else if mode&os.ModeSocket != 0 {
+		return "socket"
+	} else if mode&os.ModeCharDevice != 0 {
+		return "char_device"
+	} else if mode&os.ModeDevice != 0 {
+		return "block_device"
 	} else {
 		// Should not happen?
-		return colors["nothing"]
+		return "nothing"
 	}
 
 	if hasSuffixFromList(path, imageTypes) {
-		return colors["image"]
+		return "image"
 	}
 
 	if hasSuffixFromList(path, videoTypes) {
-		return colors["video"]
-		//return ret.Foreground(tcell.ColorHotPink)
+		return "video"
 	}
 
 	if hasSuffixFromList(path, archiveTypes) {
-		return colors["archive"]
-		//return ret.Foreground(tcell.ColorRed)
+		return "archive"
 	}
 
 	if hasSuffixFromList(path, codeTypes) {
-		return colors["code"]
-		//return ret.Foreground(tcell.ColorNavy)
+		return "code"
 	}
 
 	if hasSuffixFromList(path, audioTypes) {
-		return colors["audio"]
-		//return ret.Foreground(tcell.ColorPurple)
+		return "audio"
 	}
 
 	if hasSuffixFromList(path, documentTypes) {
-		return colors["document"]
-		//return ret.Foreground(tcell.ColorGray)
+		return "document"
 	}
 
-	return colors["nothing"]
+	// Only filled in with --sniff
+	if category := caches.sniffedCategories[path]; category != "" {
+		return category
+	}
+
+	return "nothing"
 }
 
-func FoldersAtBeginning(entries []os.DirEntry) []os.DirEntry {
-	var folders []os.DirEntry
-	var files []os.DirEntry
+// stat should be from an os.Lstat()
+func FileColor(stat os.FileInfo, path string) string {
+	category := FileCategory(stat, path)
+
+	// Extension rules from LS_COLORS or the theme file take precedence over the built-in type lists
+	if stat != nil && stat.Mode().IsRegular() && category != "executable" && category != "setuid" && category != "setgid" {
+		rule, ok := MatchExtensionColor(path)
+		if ok {
+			return rule.color
+		}
+	}
+
+	if symlinkColorIsTargetSource != "" && (category == "symlink" || category == "symlink_directory") {
+		targetStat, err := os.Stat(path)
+		if err == nil {
+			return FileColor(targetStat, path)
+		}
+	}
+
+	return colors[category]
+}
+
+func FoldersAtBeginning(entries []Entry) []Entry {
+	var folders []Entry
+	var files []Entry
 	for _, entry := range entries {
 		if entry.IsDir() {
 			folders = append(folders, entry)
@@ -197,3 +276,78 @@ func FoldersAtBeginning(entries []os.DirEntry) []os.DirEntry {
 
 	return append(folders, files...)
 }
+
+// This function taken from util.go in https://github.com/kivattt/fen
+// If maxDecimals is less than 0, e.g -1, we show the exact size down to the byte
+// https://en.wikipedia.org/wiki/Byte#Multiple-byte_units
+func BytesToHumanReadableUnitString(bytes uint64, maxDecimals int) string {
+	unitValues := []float64{
+		math.Pow(10, 3),
+		math.Pow(10, 6),
+		math.Pow(10, 9),
+		math.Pow(10, 12),
+		math.Pow(10, 15),
+		math.Pow(10, 18), // Largest unit that fits in 64 bits
+	}
+
+	unitStrings := []string{
+		"kB",
+		"MB",
+		"GB",
+		"TB",
+		"PB",
+		"EB",
+	}
+
+	if bytes < uint64(unitValues[0]) {
+		return strconv.FormatUint(bytes, 10) + " B"
+	}
+
+	for i, v := range unitValues {
+		if bytes >= uint64(v) {
+			continue
+		}
+
+		lastIndex := max(0, i-1)
+		return trimLastDecimals(strconv.FormatFloat(float64(bytes)/unitValues[lastIndex], 'f', -1, 64), maxDecimals) + " " + unitStrings[lastIndex]
+	}
+
+	return trimLastDecimals(strconv.FormatFloat(float64(bytes)/unitValues[len(unitValues)-1], 'f', -1, 64), maxDecimals) + " " + unitStrings[len(unitStrings)-1]
+}
+
+// This function taken from util.go in https://github.com/kivattt/fen
+// Trims the last decimals up to maxDecimals, does nothing if maxDecimals is less than 0, e.g -1
+func trimLastDecimals(numberString string, maxDecimals int) string {
+	if maxDecimals < 0 {
+		return numberString
+	}
+
+	dotIndex := strings.Index(numberString, ".")
+	if dotIndex == -1 {
+		return numberString
+	}
+
+	return numberString[:min(len(numberString), dotIndex+maxDecimals+1)]
+}
+
+// Calls fn for each entry concurrently, with a worker per CPU, and returns once they are all done
+func parallelForEntries(entries []Entry, fn func(Entry)) {
+	jobs := make(chan Entry)
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(runtime.NumCPU(), len(entries)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				fn(e)
+			}
+		}()
+	}
+
+	for _, e := range entries {
+		jobs <- e
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestFileCategoryMode(t *testing.T) {
+	type TestCase struct {
+		mode     fs.FileMode
+		expected string
+	}
+
+	tests := []TestCase{
+		{0o644, "nothing"},
+		{0o755, "executable"},
+		{0o755 | fs.ModeSetuid, "setuid"},
+		{0o755 | fs.ModeSetgid, "setgid"},
+		{0o744 | fs.ModeSetuid | fs.ModeSetgid, "setuid"},
+		// Not executable, so the bits do nothing
+		{0o644 | fs.ModeSetuid, "nothing"},
+		{0o644 | fs.ModeSetgid, "nothing"},
+		{fs.ModeDir | 0o755, "directory"},
+		{fs.ModeDir | 0o777 | fs.ModeSticky, "sticky_other_writable"},
+		{fs.ModeNamedPipe | 0o644, "pipe"},
+	}
+	for _, test := range tests {
+		result := FileCategory(&archiveFile{name: "file", mode: test.mode}, "file")
+		if result != test.expected {
+			t.Fatal("Expected", test.expected, "for", test.mode, "but got", result)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// How long entries stay highlighted in --watch after they appear
+const highlightDuration = 2 * time.Second
+
+// How long to wait for a burst of changes to settle before redrawing
+const watchSettleDuration = 50 * time.Millisecond
+
+// Entries that appeared recently in --watch, keyed by Entry.path
+var highlightedEntries = make(map[string]bool)
+
+// Returns the paths to watch for changes: the paths given as arguments, and the directories in the trees of --recursive
+func WatchedPaths(paths []string, trees []DirectoryTree, options Options) []string {
+	ret := slices.Clone(paths)
+
+	// Paths inside archives are watched through the archive file
+	if options.intoArchives {
+		for i, path := range ret {
+			if archivePath, _, ok := SplitArchivePath(path); ok {
+				ret[i] = archivePath
+			}
+		}
+	}
+
+	for _, tree := range trees {
+		for _, e := range tree.AllEntries() {
+			if _, archived := e.DirEntry.(*archiveFile); archived {
+				continue
+			}
+
+			if e.IsDir() && (options.all || !strings.HasPrefix(e.Name(), ".")) {
+				ret = append(ret, e.path)
+			}
+		}
+	}
+
+	return ret
+}
+
+// Lists the entries from collect, and lists them again whenever they change until Ctrl-C.
+// Entries that weren't there in the previous listing are highlighted for a moment
+func Watch(paths []string, collect func() ([]Entry, []DirectoryTree), options Options) {
+	watcher, err := newFileWatcher()
+	if err != nil {
+		printError("Unable to watch for changes: "+err.Error(), options.colorEnabled)
+		os.Exit(exitSeriousProblem)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	var previous map[string]bool // nil until the first listing
+	appearedAt := make(map[string]time.Time)
+	var unhighlight <-chan time.Time
+
+	redraw := func() {
+		clearCaches()
+		exitStatus = 0 // Exits with the status of the last listing
+		allEntries, trees := collect()
+
+		current := make(map[string]bool)
+		listed := allEntries
+		for _, tree := range trees {
+			listed = append(listed, tree.AllEntries()...)
+		}
+
+		now := time.Now()
+		highlightedEntries = make(map[string]bool)
+		var nextUnhighlight time.Time
+		for _, e := range listed {
+			current[e.path] = true
+			if previous != nil && !previous[e.path] {
+				appearedAt[e.path] = now
+			}
+
+			since, ok := appearedAt[e.path]
+			if !ok {
+				continue
+			}
+
+			if now.Sub(since) >= highlightDuration {
+				delete(appearedAt, e.path)
+				continue
+			}
+
+			highlightedEntries[e.path] = true
+			if nextUnhighlight.IsZero() || since.Add(highlightDuration).Before(nextUnhighlight) {
+				nextUnhighlight = since.Add(highlightDuration)
+			}
+		}
+		previous = current
+
+		unhighlight = nil
+		if !nextUnhighlight.IsZero() {
+			unhighlight = time.After(time.Until(nextUnhighlight))
+		}
+
+		fmt.Print("\x1b[H\x1b[2J") // Clear the screen
+		PrintListing(allEntries, trees, options)
+
+		// Adding a path that is already watched does nothing
+		for _, path := range WatchedPaths(paths, trees, options) {
+			watcher.Add(path)
+		}
+	}
+
+	fmt.Print("\x1b[?25l") // Hide the cursor
+	redraw()
+
+	for {
+		select {
+		case <-interrupt:
+			fmt.Print("\x1b[?25h") // Show the cursor
+			return
+		case <-unhighlight:
+			redraw()
+		case <-watcher.events:
+			time.Sleep(watchSettleDuration)
+			select {
+			case <-watcher.events:
+			default:
+			}
+			redraw()
+		}
+	}
+}
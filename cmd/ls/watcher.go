@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+)
+
+// Only Linux is supported, since it uses inotify
+type fileWatcher struct {
+	events chan struct{}
+}
+
+func newFileWatcher() (*fileWatcher, error) {
+	return nil, errors.New("--watch is only supported on Linux")
+}
+
+func (watcher *fileWatcher) Add(path string) error {
+	return nil
+}
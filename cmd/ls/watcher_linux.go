@@ -0,0 +1,48 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const watchedEvents = unix.IN_CREATE | unix.IN_DELETE | unix.IN_DELETE_SELF | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_MOVE_SELF | unix.IN_MODIFY | unix.IN_ATTRIB
+
+// Watches files and directories with inotify
+type fileWatcher struct {
+	fd     int
+	events chan struct{} // Receives when anything changed, multiple changes may only be sent once
+}
+
+func newFileWatcher() (*fileWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &fileWatcher{fd: fd, events: make(chan struct{}, 1)}
+	go watcher.readEvents()
+	return watcher, nil
+}
+
+// The events themselves don't matter, the whole listing is redrawn anyway
+func (watcher *fileWatcher) readEvents() {
+	buffer := make([]byte, 64*1024)
+	for {
+		_, err := unix.Read(watcher.fd, buffer)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		select {
+		case watcher.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (watcher *fileWatcher) Add(path string) error {
+	_, err := unix.InotifyAddWatch(watcher.fd, path, watchedEvents)
+	return err
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const selinuxXattr = "security.selinux"
+const capabilityXattr = "security.capability"
+
+// POSIX ACLs are stored as these extended attributes
+var aclXattrs = [...]string{
+	"system.posix_acl_access",
+	"system.posix_acl_default",
+}
+
+// Names of the Linux capabilities by bit number, see capabilities(7)
+var capabilityNames = [...]string{
+	"chown", "dac_override", "dac_read_search", "fowner", "fsetid", "kill", "setgid", "setuid",
+	"setpcap", "linux_immutable", "net_bind_service", "net_broadcast", "net_admin", "net_raw", "ipc_lock", "ipc_owner",
+	"sys_module", "sys_rawio", "sys_chroot", "sys_ptrace", "sys_pacct", "sys_admin", "sys_boot", "sys_nice",
+	"sys_resource", "sys_time", "sys_tty_config", "mknod", "lease", "audit_write", "audit_control", "setfcap",
+	"mac_override", "mac_admin", "syslog", "wake_alarm", "block_suspend", "audit_read", "perfmon", "bpf",
+	"checkpoint_restore",
+}
+
+// Returns the suffix of the mode in --long mode like GNU ls and macOS ls:
+// '+' if the file has an ACL, '@' if it has other extended attributes, otherwise "".
+// SELinux labels are left out, since every file has one when SELinux is enabled
+func XattrSuffix(path string) string {
+	names, err := ListXattrs(path)
+	if err != nil {
+		return ""
+	}
+
+	suffix := ""
+	for _, name := range names {
+		if slices.Contains(aclXattrs[:], name) {
+			return "+"
+		}
+		if name != selinuxXattr {
+			suffix = "@"
+		}
+	}
+
+	return suffix
+}
+
+// Returns the SELinux label of the file, or "?" if it has none like GNU ls --context
+func SELinuxContext(path string) string {
+	value, err := GetXattr(path, selinuxXattr)
+	if err != nil || len(value) == 0 {
+		return "?"
+	}
+
+	return strings.TrimRight(string(value), "\x00")
+}
+
+// Decodes a security.capability value like getcap(8) does, e.g. "cap_net_bind_service,cap_net_raw=ep".
+// ok is false if it isn't a valid capability set
+func FormatCapabilities(value []byte) (string, bool) {
+	// struct vfs_cap_data: a magic number with the revision and flags, then the permitted and inheritable sets in 32-bit halves
+	if len(value) < 12 {
+		return "", false
+	}
+
+	magic := binary.LittleEndian.Uint32(value)
+	effective := magic&1 != 0
+	permitted := uint64(binary.LittleEndian.Uint32(value[4:]))
+	inheritable := uint64(binary.LittleEndian.Uint32(value[8:]))
+
+	// Revisions 2 and 3 have 64-bit sets, revision 3 adds the root user ID of the namespace at the end
+	if revision := magic &^ 1; revision == 0x02000000 || revision == 0x03000000 {
+		if len(value) < 20 {
+			return "", false
+		}
+		permitted |= uint64(binary.LittleEndian.Uint32(value[12:])) << 32
+		inheritable |= uint64(binary.LittleEndian.Uint32(value[16:])) << 32
+	} else if revision != 0x01000000 {
+		return "", false
+	}
+
+	// Capabilities with the same flags are grouped together
+	var groupOrder []string
+	groups := make(map[string][]string)
+	for bit := 0; bit < 64; bit++ {
+		flags := ""
+		if permitted&(1<<bit) != 0 && effective {
+			flags += "e"
+		}
+		if inheritable&(1<<bit) != 0 {
+			flags += "i"
+		}
+		if permitted&(1<<bit) != 0 {
+			flags += "p"
+		}
+		if flags == "" {
+			continue
+		}
+
+		name := "cap_" + strconv.Itoa(bit)
+		if bit < len(capabilityNames) {
+			name = "cap_" + capabilityNames[bit]
+		}
+
+		if _, ok := groups[flags]; !ok {
+			groupOrder = append(groupOrder, flags)
+		}
+		groups[flags] = append(groups[flags], name)
+	}
+
+	var ret []string
+	for _, flags := range groupOrder {
+		ret = append(ret, strings.Join(groups[flags], ",")+"="+flags)
+	}
+	return strings.Join(ret, " "), true
+}
+
+// Returns the value of an extended attribute as text.
+// Capabilities are decoded, printable text is quoted, anything else is shown as hex like getfattr(1)
+func FormatXattrValue(name string, value []byte) string {
+	if name == capabilityXattr {
+		if capabilities, ok := FormatCapabilities(value); ok {
+			return capabilities
+		}
+	}
+
+	// Values are often NUL-terminated strings
+	text := strings.TrimSuffix(string(value), "\x00")
+	printable := utf8.ValidString(text) && strings.IndexFunc(text, func(r rune) bool { return !unicode.IsPrint(r) }) == -1
+	if printable {
+		return strconv.Quote(text)
+	}
+
+	return "0x" + hex.EncodeToString(value)
+}
+
+// Returns the extended attributes of the file for --xattrs, like "user.origin, security.capability".
+// With values, it is like `user.origin="https://example.com", security.capability=cap_net_raw=ep`
+func FormatXattrs(path string, withValues bool) string {
+	names, err := ListXattrs(path)
+	if err != nil {
+		return ""
+	}
+	slices.Sort(names)
+
+	if !withValues {
+		return strings.Join(names, ", ")
+	}
+
+	var ret []string
+	for _, name := range names {
+		value, err := GetXattr(path, name)
+		if err != nil {
+			ret = append(ret, name+"=?")
+			continue
+		}
+		ret = append(ret, name+"="+FormatXattrValue(name, value))
+	}
+	return strings.Join(ret, ", ")
+}
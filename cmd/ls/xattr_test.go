@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormatCapabilities(t *testing.T) {
+	type TestCase struct {
+		value    string
+		expected string
+		ok       bool
+	}
+
+	tests := []TestCase{
+		{"", "", false},
+		// Revision 2, effective, cap_net_bind_service and cap_net_raw permitted
+		{"\x01\x00\x00\x02\x00\x24\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", "cap_net_bind_service,cap_net_raw=ep", true},
+		// Revision 2, cap_chown permitted and inheritable, cap_kill inheritable
+		{"\x00\x00\x00\x02\x01\x00\x00\x00\x21\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", "cap_chown=ip cap_kill=i", true},
+		// Revision 2, cap_bpf permitted in the upper half
+		{"\x00\x00\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x80\x00\x00\x00\x00\x00\x00\x00", "cap_bpf=p", true},
+		// Unknown revision
+		{"\x00\x00\x00\x09\x00\x00\x00\x00\x00\x00\x00\x00", "", false},
+	}
+	for _, test := range tests {
+		result, ok := FormatCapabilities([]byte(test.value))
+		if result != test.expected || ok != test.ok {
+			t.Fatal("Expected \""+test.expected+"\" but got \""+result+"\", ok:", ok)
+		}
+	}
+}
+
+func TestFormatXattrValue(t *testing.T) {
+	type TestCase struct {
+		name     string
+		value    string
+		expected string
+	}
+
+	tests := []TestCase{
+		{"user.origin", "https://example.com", `"https://example.com"`},
+		{"security.selinux", "unconfined_u:object_r:user_home_t:s0\x00", `"unconfined_u:object_r:user_home_t:s0"`},
+		{"user.binary", "\x00\x01\xff", "0x0001ff"},
+		{"security.capability", "invalid", `"invalid"`},
+	}
+	for _, test := range tests {
+		result := FormatXattrValue(test.name, []byte(test.value))
+		if result != test.expected {
+			t.Fatal("Expected " + test.expected + " but got " + result)
+		}
+	}
+}
@@ -5,10 +5,8 @@ go 1.22.3
 require (
 	github.com/kivattt/getopt v0.0.0-20240907012637-674e0e42e04f
 	github.com/kivattt/gogitstatus v0.0.0-20250108154353-83d8075e2b11
+	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
 	golang.org/x/term v0.24.0
 )
 
-require (
-	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06 // indirect
-	golang.org/x/sys v0.25.0 // indirect
-)
+require golang.org/x/sys v0.25.0